@@ -0,0 +1,71 @@
+// bayes-train обучает классификатор спама заново на размеченных нарушениях
+// из spam_violations и сохраняет модель в bayes_tokens. Работающие боты
+// подхватывают новую модель при ежечасной перезагрузке.
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+
+	"go_payment_bot/config"
+	"go_payment_bot/database"
+	"go_payment_bot/moderation"
+
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	dryRun := flag.Bool("dry-run", false, "только посчитать модель, не сохраняя её")
+	flag.Parse()
+
+	_ = godotenv.Load()
+	cfg := config.Load()
+	if cfg.DatabaseURL == "" {
+		log.Fatal("DATABASE_URL не установлен")
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	db, err := database.New(ctx, cfg.DatabaseURL)
+	if err != nil {
+		log.Fatalf("Ошибка подключения к БД: %v", err)
+	}
+	defer db.Close()
+
+	samples, err := db.GetBayesTrainingSet(ctx)
+	if err != nil {
+		log.Fatalf("Ошибка загрузки обучающей выборки: %v", err)
+	}
+
+	model := moderation.NewBayes()
+	labels := make(map[int]string, len(samples))
+	for _, s := range samples {
+		tokens := moderation.Tokenize(s.Text)
+		if len(tokens) == 0 {
+			continue
+		}
+		model.Learn(tokens, s.Label == database.ModVerdictSpam, 1)
+		labels[s.ViolationID] = s.Label
+	}
+
+	counts := model.Counts()
+	tokens := make([]database.BayesToken, 0, len(counts))
+	for t, c := range counts {
+		tokens = append(tokens, database.BayesToken{Token: t, SpamCount: c.Spam, HamCount: c.Ham})
+	}
+
+	docs := counts[""]
+	log.Printf("Обучающая выборка: %d спам и %d не спам сообщений, %d токенов", docs.Spam, docs.Ham, len(counts)-1)
+	if *dryRun {
+		return
+	}
+
+	if err := db.ReplaceBayesModel(ctx, tokens, labels); err != nil {
+		log.Fatalf("Ошибка сохранения модели: %v", err)
+	}
+	log.Println("Модель сохранена")
+}
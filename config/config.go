@@ -1,8 +1,11 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 )
 
 type Config struct {
@@ -10,12 +13,42 @@ type Config struct {
 	PaymentProviderToken string
 	DatabaseURL          string
 	LogChannelID         int64
+	InstanceID           string  // имя инстанса в логах выбора лидера
+	AdminIDs             []int64 // пользователи, которым доступны команды модерации
 
 	// Дефолтные значения для новых тем
 	DefaultPrice        int
 	DefaultDurationDays int
 	DefaultMaxTextLen   int
 	DefaultMaxPhotos    int
+	DefaultTimezone     string // для групп без своего timezone
+
+	// Сколько раз пытаться удалить просроченный пост перед алертом
+	DeleteMaxAttempts int
+	// За сколько до окончания срока напомнить продавцу (0 — не напоминать)
+	ExpiryReminderBefore time.Duration
+
+	// Пороги оценки спама для групп без своих настроек
+	SpamWarnThreshold   int
+	SpamDeleteThreshold int
+
+	// Флуд: больше FloodUserLimit сообщений от пользователя или FloodGroupLimit
+	// в группе за FloodWindow
+	FloodUserLimit  int
+	FloodGroupLimit int
+	FloodWindow     time.Duration
+	// Рейд: почти одинаковый текст от RaidUsers разных пользователей за RaidWindow
+	RaidUsers  int
+	RaidWindow time.Duration
+	// Мьют за флуд и рейд
+	FloodMuteDuration time.Duration
+	// Медленный режим, который включает бот: не чаще раза в SlowModeDelay
+	// на пользователя в течение SlowModeDuration
+	SlowModeDelay    time.Duration
+	SlowModeDuration time.Duration
+
+	// Время на ответ капчи для групп без своего значения
+	CaptchaTimeout time.Duration
 
 	TestMode bool
 }
@@ -25,6 +58,19 @@ func Load() *Config {
 	duration, _ := strconv.Atoi(getEnv("DEFAULT_DURATION_DAYS", "7"))
 	maxText, _ := strconv.Atoi(getEnv("DEFAULT_MAX_TEXT_LENGTH", "1000"))
 	maxPhotos, _ := strconv.Atoi(getEnv("DEFAULT_MAX_PHOTOS", "5"))
+	deleteAttempts, _ := strconv.Atoi(getEnv("DELETE_MAX_ATTEMPTS", "5"))
+	reminderBefore, _ := time.ParseDuration(getEnv("EXPIRY_REMINDER_BEFORE", "24h"))
+	spamWarn, _ := strconv.Atoi(getEnv("SPAM_WARN_THRESHOLD", "30"))
+	spamDelete, _ := strconv.Atoi(getEnv("SPAM_DELETE_THRESHOLD", "60"))
+	floodUser, _ := strconv.Atoi(getEnv("FLOOD_USER_LIMIT", "5"))
+	floodGroup, _ := strconv.Atoi(getEnv("FLOOD_GROUP_LIMIT", "30"))
+	floodWindow, _ := time.ParseDuration(getEnv("FLOOD_WINDOW", "10s"))
+	raidUsers, _ := strconv.Atoi(getEnv("RAID_USERS", "3"))
+	raidWindow, _ := time.ParseDuration(getEnv("RAID_WINDOW", "1m"))
+	floodMute, _ := time.ParseDuration(getEnv("FLOOD_MUTE_DURATION", "30m"))
+	slowDelay, _ := time.ParseDuration(getEnv("SLOWMODE_DELAY", "30s"))
+	slowDuration, _ := time.ParseDuration(getEnv("SLOWMODE_DURATION", "15m"))
+	captchaTimeout, _ := time.ParseDuration(getEnv("CAPTCHA_TIMEOUT", "2m"))
 	logChannel, _ := strconv.ParseInt(getEnv("LOG_CHANNEL_ID", "0"), 10, 64)
 
 	return &Config{
@@ -35,7 +81,23 @@ func Load() *Config {
 		DefaultDurationDays:  duration,
 		DefaultMaxTextLen:    maxText,
 		DefaultMaxPhotos:     maxPhotos,
+		DefaultTimezone:      getEnv("DEFAULT_TIMEZONE", "Asia/Krasnoyarsk"),
+		DeleteMaxAttempts:    deleteAttempts,
+		ExpiryReminderBefore: reminderBefore,
+		SpamWarnThreshold:    spamWarn,
+		SpamDeleteThreshold:  spamDelete,
+		FloodUserLimit:       floodUser,
+		FloodGroupLimit:      floodGroup,
+		FloodWindow:          floodWindow,
+		RaidUsers:            raidUsers,
+		RaidWindow:           raidWindow,
+		FloodMuteDuration:    floodMute,
+		SlowModeDelay:        slowDelay,
+		SlowModeDuration:     slowDuration,
+		CaptchaTimeout:       captchaTimeout,
 		LogChannelID:         logChannel,
+		InstanceID:           getEnv("INSTANCE_ID", defaultInstanceID()),
+		AdminIDs:             parseIDs(getEnv("ADMIN_IDS", "")),
 		TestMode:             getEnv("TEST_MODE", "false") == "true",
 	}
 }
@@ -46,3 +108,22 @@ func getEnv(key, def string) string {
 	}
 	return def
 }
+
+// parseIDs разбирает список id через запятую, некорректные значения пропускает
+func parseIDs(s string) []int64 {
+	var ids []int64
+	for _, part := range strings.Split(s, ",") {
+		if id, err := strconv.ParseInt(strings.TrimSpace(part), 10, 64); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func defaultInstanceID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "bot"
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
@@ -15,12 +15,70 @@ const (
 )
 
 type Group struct {
-	ID        int64
-	Title     string
-	IsActive  bool
+	ID       int64
+	Title    string
+	IsActive bool
+	Timezone *string
+	// Пороги оценки спама (nil — из конфигурации)
+	SpamWarnThreshold   *int
+	SpamDeleteThreshold *int
+	// Теневой режим: нарушения только записываются
+	SpamShadow bool
+	// Сообщения от имени чужих каналов и через inline-ботов
+	ChannelPolicy SenderPolicy
+	ViaBotPolicy  SenderPolicy
+	// Карточки контактов, места и геопозиции, пересылки из незнакомых каналов, истории
+	ContactPolicy  ContentPolicy
+	LocationPolicy ContentPolicy
+	ForwardPolicy  ContentPolicy
+	StoryPolicy    ContentPolicy
+	// Действия при флуде пользователя и рейде
+	FloodAction FloodAction
+	RaidAction  FloodAction
+	// Капча для новых участников; таймаут в секундах (nil — из конфигурации)
+	CaptchaEnabled bool
+	CaptchaTimeout *int
+	// Ограничения для новичков: первые NewcomerMessages сообщений или
+	// первые NewcomerHours часов в группе (0 — без ограничения)
+	NewcomerMessages   int
+	NewcomerHours      int
+	NewcomerNoLinks    bool
+	NewcomerNoCaptions bool
+	NewcomerHold       bool
+	// Детекторы номеров карт, криптокошельков и email
+	DetectCards  bool
+	DetectCrypto bool
+	DetectEmails bool
+	// Распознавание QR-кодов на фото
+	QRCheck   bool
 	CreatedAt time.Time
 }
 
+// SenderPolicy — что делать с сообщениями от имени канала или через inline-бота
+type SenderPolicy string
+
+const (
+	SenderAllow    SenderPolicy = "allow"
+	SenderModerate SenderPolicy = "moderate"
+	SenderDelete   SenderPolicy = "delete"
+)
+
+type FloodAction string
+
+const (
+	FloodDelete   FloodAction = "delete"   // удалить сообщения
+	FloodMute     FloodAction = "mute"     // удалить и замьютить авторов
+	FloodSlowMode FloodAction = "slowmode" // включить медленный режим в группе
+)
+
+// ContentPolicy — разрешены ли в группе вложения определённого вида
+type ContentPolicy string
+
+const (
+	ContentAllow ContentPolicy = "allow"
+	ContentDeny  ContentPolicy = "deny"
+)
+
 type Topic struct {
 	ID                int
 	GroupID           int64
@@ -75,6 +133,30 @@ type Post struct {
 	DeletedAt     *time.Time
 }
 
+type ScheduledPostStatus string
+
+const (
+	ScheduledPending    ScheduledPostStatus = "pending"
+	ScheduledPublishing ScheduledPostStatus = "publishing"
+	ScheduledPublished  ScheduledPostStatus = "published"
+	ScheduledCancelled  ScheduledPostStatus = "cancelled"
+	ScheduledFailed     ScheduledPostStatus = "failed"
+)
+
+// ScheduledPost — объявление, отложенное до выбранного времени
+type ScheduledPost struct {
+	ID           int
+	UserID       int64
+	TopicID      int
+	ContentText  *string
+	PhotoFileIDs []string
+	PublishAt    time.Time
+	Status       ScheduledPostStatus
+	PostID       *int
+	CreatedAt    time.Time
+	PublishedAt  *time.Time
+}
+
 type Payment struct {
 	ID                int
 	UserID            int64
@@ -95,6 +177,33 @@ type ExpiredPost struct {
 	InternalTopicID int
 	UserID          int64
 	ExpiresAt       time.Time
+	// Сколько раз уже пытались удалить
+	DeletionAttempts int
+}
+
+type MessageDeletionStatus string
+
+const (
+	MessageDeletionPending  MessageDeletionStatus = "pending"
+	MessageDeletionDeleted  MessageDeletionStatus = "deleted"
+	MessageDeletionGone     MessageDeletionStatus = "gone"
+	MessageDeletionNoRights MessageDeletionStatus = "no_rights"
+)
+
+// PostMessageDeletion — статус удаления одного сообщения поста
+type PostMessageDeletion struct {
+	PostID    int
+	ChatID    int64
+	MessageID int
+	Status    MessageDeletionStatus
+	Attempts  int
+	LastError *string
+	UpdatedAt time.Time
+}
+
+// IsFinal — сообщения в чате больше нет
+func (d *PostMessageDeletion) IsFinal() bool {
+	return d.Status == MessageDeletionDeleted || d.Status == MessageDeletionGone
 }
 
 type SpamViolation struct {
@@ -105,7 +214,75 @@ type SpamViolation struct {
 	MessageText   *string
 	ViolationType string
 	MatchFound    *string
-	CreatedAt     time.Time
+	EntityType    *string
+	SenderChatID  *int64 // сообщение от имени канала; UserID тогда — id канала
+	ViaBotID      *int64
+	Score         int
+	Action        string
+	Signals       []byte  // JSON: сигналы с весами
+	IsShadow      bool    // теневое срабатывание: сообщение не удалялось
+	PhotoFileID   *string // фото задержанного сообщения; MessageText тогда — подпись
+	ModVerdict    *string
+	ReviewedBy    *int64
+	ReviewedAt    *time.Time
+	// Апелляция пользователя
+	AppealStatus     *AppealStatus
+	AppealedAt       *time.Time
+	AppealResolvedBy *int64
+	AppealResolvedAt *time.Time
+	CreatedAt        time.Time
+}
+
+type AppealStatus string
+
+const (
+	AppealPending     AppealStatus = "pending"
+	AppealRestored    AppealStatus = "restored"    // сообщение восстановлено модератором
+	AppealWhitelisted AppealStatus = "whitelisted" // домен добавлен в белый список, сообщение восстановлено
+	AppealRejected    AppealStatus = "rejected"
+)
+
+// Вердикты модераторов по теневым срабатываниям
+const (
+	ModVerdictSpam = "spam"
+	ModVerdictHam  = "ham"
+)
+
+// ShadowStats — теневые срабатывания и их проверка модераторами
+type ShadowStats struct {
+	GroupID   int64
+	RuleID    int // 0 — статистика по группе
+	Hits      int
+	Confirmed int // модератор подтвердил спам
+	Rejected  int // модератор отметил как ложное срабатывание
+}
+
+type JobStatus string
+
+const (
+	JobPending JobStatus = "pending"
+	JobRunning JobStatus = "running"
+	JobDone    JobStatus = "done"
+	JobFailed  JobStatus = "failed"
+)
+
+// Job — задача из очереди jobs
+type Job struct {
+	ID          int64
+	Type        string
+	Payload     []byte
+	UniqueKey   *string
+	RunAt       time.Time
+	Status      JobStatus
+	Attempts    int
+	MaxAttempts int
+	LastError   *string
+	CreatedAt   time.Time
+}
+
+// IsLastAttempt — после неудачи этой попытки задача не будет повторена
+func (j *Job) IsLastAttempt() bool {
+	return j.Attempts >= j.MaxAttempts
 }
 
 type AllowedDomain struct {
@@ -115,3 +292,128 @@ type AllowedDomain struct {
 	IsActive    bool
 	CreatedAt   time.Time
 }
+
+type SpamRuleKind string
+
+const (
+	SpamRuleRegex    SpamRuleKind = "regex"
+	SpamRuleKeywords SpamRuleKind = "keywords"
+)
+
+// SpamRule — правило детектора, которое ведут администраторы
+type SpamRule struct {
+	ID        int
+	GroupID   *int64 // nil — для всех групп
+	Name      string
+	Kind      SpamRuleKind
+	Pattern   string
+	Weight    int
+	Action    *string // warn / delete — действие независимо от оценки
+	IsEnabled bool
+	IsShadow  bool // срабатывания только записываются
+	CreatedAt time.Time
+}
+
+type SanctionAction string
+
+const (
+	SanctionWarn SanctionAction = "warn"
+	SanctionMute SanctionAction = "mute"
+	SanctionBan  SanctionAction = "ban"
+)
+
+// SanctionStep — ступень эскалации: Violations-е нарушение за WindowHours → Action
+type SanctionStep struct {
+	ID              int
+	GroupID         *int64 // nil — ступени по умолчанию
+	Violations      int
+	WindowHours     int
+	Action          SanctionAction
+	DurationMinutes *int // для mute; nil — бессрочно
+}
+
+// Sanction — наложенная санкция (журнал)
+type Sanction struct {
+	ID          int
+	UserID      int64
+	GroupID     int64
+	Action      SanctionAction
+	Until       *time.Time // nil — бессрочно
+	ViolationID *int
+	Reason      *string
+	CreatedAt   time.Time
+	LiftedAt    *time.Time
+	LiftedBy    *int64
+}
+
+// TrustedMember — участник, чьи сообщения не проверяются на спам
+type TrustedMember struct {
+	ID        int
+	UserID    int64
+	GroupID   *int64 // nil — во всех группах
+	Note      *string
+	AddedBy   *int64
+	CreatedAt time.Time
+}
+
+type VerificationStatus string
+
+const (
+	VerificationPending VerificationStatus = "pending"
+	VerificationPassed  VerificationStatus = "passed"
+	VerificationFailed  VerificationStatus = "failed"
+)
+
+// BayesToken — счётчики токена в модели классификатора.
+// Пустой токен хранит число спам- и не спам-сообщений.
+type BayesToken struct {
+	Token     string
+	SpamCount int
+	HamCount  int
+}
+
+// BayesSample — размеченное сообщение для обучения классификатора
+type BayesSample struct {
+	ViolationID int
+	Text        string
+	Label       string // spam / ham
+}
+
+// GroupMember — участник группы, замеченный ботом
+type GroupMember struct {
+	UserID        int64
+	GroupID       int64
+	FirstSeenAt   time.Time
+	MessageCount  int
+	LastMessageAt *time.Time
+	// Нарушения в группе (без теневых и отменённых модератором)
+	Violations int
+}
+
+// MemberVerification — проверка нового участника группы капчей
+type MemberVerification struct {
+	ID         int
+	UserID     int64
+	GroupID    int64
+	Status     VerificationStatus
+	Answer     int
+	MessageID  *int
+	ExpiresAt  time.Time
+	CreatedAt  time.Time
+	ResolvedAt *time.Time
+}
+
+// PhotoHash — перцептивный хэш фото из объявления или сообщения в группе
+type PhotoHash struct {
+	ID           int
+	FileUniqueID string
+	Hash         uint64
+	UserID       int64
+	ChatID       int64
+	ThreadID     int
+	MessageID    int
+	PostID       *int
+	CreatedAt    time.Time
+	// Расстояние до искомого хэша в битах
+	Distance int
+}
@@ -2,7 +2,11 @@ package database
 
 import (
 	"context"
+	"errors"
 	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 )
 
 // ============================================
@@ -14,11 +18,39 @@ func (db *DB) GetOrCreateGroup(ctx context.Context, id int64, title string) (*Gr
 		INSERT INTO groups (id, title)
 		VALUES ($1, $2)
 		ON CONFLICT (id) DO UPDATE SET title = EXCLUDED.title
-		RETURNING id, title, is_active, created_at`
+		RETURNING id, title, is_active, timezone, spam_warn_threshold, spam_delete_threshold, spam_shadow,
+		          channel_policy, via_bot_policy, contact_policy, location_policy, forward_policy, story_policy,
+		          flood_action, raid_action, captcha_enabled, captcha_timeout,
+		          newcomer_messages, newcomer_hours, newcomer_no_links, newcomer_no_captions, newcomer_hold,
+		          detect_cards, detect_crypto, detect_emails, qr_check, created_at`
 
 	var g Group
 	err := db.Pool.QueryRow(ctx, query, id, title).Scan(
-		&g.ID, &g.Title, &g.IsActive, &g.CreatedAt,
+		&g.ID, &g.Title, &g.IsActive, &g.Timezone, &g.SpamWarnThreshold, &g.SpamDeleteThreshold, &g.SpamShadow,
+		&g.ChannelPolicy, &g.ViaBotPolicy, &g.ContactPolicy, &g.LocationPolicy, &g.ForwardPolicy, &g.StoryPolicy,
+		&g.FloodAction, &g.RaidAction, &g.CaptchaEnabled, &g.CaptchaTimeout,
+		&g.NewcomerMessages, &g.NewcomerHours, &g.NewcomerNoLinks, &g.NewcomerNoCaptions, &g.NewcomerHold,
+		&g.DetectCards, &g.DetectCrypto, &g.DetectEmails, &g.QRCheck, &g.CreatedAt,
+	)
+	return &g, err
+}
+
+func (db *DB) GetGroup(ctx context.Context, id int64) (*Group, error) {
+	query := `
+		SELECT id, title, is_active, timezone, spam_warn_threshold, spam_delete_threshold, spam_shadow,
+		       channel_policy, via_bot_policy, contact_policy, location_policy, forward_policy, story_policy,
+		       flood_action, raid_action, captcha_enabled, captcha_timeout,
+		       newcomer_messages, newcomer_hours, newcomer_no_links, newcomer_no_captions, newcomer_hold,
+		       detect_cards, detect_crypto, detect_emails, qr_check, created_at
+		FROM groups WHERE id = $1`
+
+	var g Group
+	err := db.Pool.QueryRow(ctx, query, id).Scan(
+		&g.ID, &g.Title, &g.IsActive, &g.Timezone, &g.SpamWarnThreshold, &g.SpamDeleteThreshold, &g.SpamShadow,
+		&g.ChannelPolicy, &g.ViaBotPolicy, &g.ContactPolicy, &g.LocationPolicy, &g.ForwardPolicy, &g.StoryPolicy,
+		&g.FloodAction, &g.RaidAction, &g.CaptchaEnabled, &g.CaptchaTimeout,
+		&g.NewcomerMessages, &g.NewcomerHours, &g.NewcomerNoLinks, &g.NewcomerNoCaptions, &g.NewcomerHold,
+		&g.DetectCards, &g.DetectCrypto, &g.DetectEmails, &g.QRCheck, &g.CreatedAt,
 	)
 	return &g, err
 }
@@ -135,6 +167,15 @@ func (db *DB) BanUser(ctx context.Context, userID int64, reason string) error {
 	return err
 }
 
+// UnbanUser снимает бан в боте (только если пользователь забанен)
+func (db *DB) UnbanUser(ctx context.Context, userID int64) error {
+	query := `
+		UPDATE users SET state = 'none', banned_at = NULL, ban_reason = NULL
+		WHERE id = $1 AND state = 'banned'`
+	_, err := db.Pool.Exec(ctx, query, userID)
+	return err
+}
+
 func (db *DB) SetUserEmail(ctx context.Context, userID int64, email string) error {
 	query := `UPDATE users SET email = $1 WHERE id = $2`
 	_, err := db.Pool.Exec(ctx, query, email, userID)
@@ -203,23 +244,53 @@ func (db *DB) CreatePost(ctx context.Context, messageID int, allMessageIDs []int
 	return &p, err
 }
 
-func (db *DB) GetExpiredPosts(ctx context.Context) ([]ExpiredPost, error) {
+// GetExpiredPost возвращает неудалённый пост для снятия с публикации
+func (db *DB) GetExpiredPost(ctx context.Context, id int) (*ExpiredPost, error) {
 	query := `
-		SELECT p.id, p.message_id, p.all_message_ids, t.group_id, t.topic_id, t.id, p.user_id, p.expires_at
+		SELECT p.id, p.message_id, p.all_message_ids, t.group_id, t.topic_id, t.id, p.user_id, p.expires_at,
+		       p.deletion_attempts
 		FROM posts p
 		JOIN topics t ON t.id = p.topic_id
-		WHERE p.is_deleted = FALSE AND p.expires_at < NOW()`
+		WHERE p.id = $1 AND p.is_deleted = FALSE AND p.deletion_status IS DISTINCT FROM 'failed'`
 
+	var p ExpiredPost
+	err := db.Pool.QueryRow(ctx, query, id).Scan(
+		&p.ID, &p.MessageID, &p.AllMessageIDs, &p.ChatID, &p.TopicID, &p.InternalTopicID, &p.UserID, &p.ExpiresAt,
+		&p.DeletionAttempts,
+	)
+	return &p, err
+}
+
+func (db *DB) GetPost(ctx context.Context, id int) (*Post, error) {
+	query := `
+		SELECT id, message_id, all_message_ids, topic_id, user_id, content_text, photo_file_ids,
+		       created_at, expires_at, is_deleted, deleted_at
+		FROM posts
+		WHERE id = $1`
+
+	var p Post
+	err := db.Pool.QueryRow(ctx, query, id).Scan(
+		&p.ID, &p.MessageID, &p.AllMessageIDs, &p.TopicID, &p.UserID, &p.ContentText, &p.PhotoFileIDs,
+		&p.CreatedAt, &p.ExpiresAt, &p.IsDeleted, &p.DeletedAt,
+	)
+	return &p, err
+}
+
+// GetPostsAwaitingReminder — активные посты, напоминание о которых ещё не отправлено
+func (db *DB) GetPostsAwaitingReminder(ctx context.Context) ([]Post, error) {
+	query := `
+		SELECT id, expires_at FROM posts
+		WHERE is_deleted = FALSE AND expiry_reminder_sent_at IS NULL AND expires_at > NOW()`
 	rows, err := db.Pool.Query(ctx, query)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var posts []ExpiredPost
+	var posts []Post
 	for rows.Next() {
-		var p ExpiredPost
-		if err := rows.Scan(&p.ID, &p.MessageID, &p.AllMessageIDs, &p.ChatID, &p.TopicID, &p.InternalTopicID, &p.UserID, &p.ExpiresAt); err != nil {
+		var p Post
+		if err := rows.Scan(&p.ID, &p.ExpiresAt); err != nil {
 			return nil, err
 		}
 		posts = append(posts, p)
@@ -227,8 +298,204 @@ func (db *DB) GetExpiredPosts(ctx context.Context) ([]ExpiredPost, error) {
 	return posts, rows.Err()
 }
 
+// MarkExpiryReminderSent отмечает отправку напоминания, false — если уже отправлено
+func (db *DB) MarkExpiryReminderSent(ctx context.Context, id int) (bool, error) {
+	query := `
+		UPDATE posts SET expiry_reminder_sent_at = NOW()
+		WHERE id = $1 AND is_deleted = FALSE AND expiry_reminder_sent_at IS NULL`
+	tag, err := db.Pool.Exec(ctx, query, id)
+	return tag.RowsAffected() > 0, err
+}
+
+// ExtendPost продлевает пост на days дней от текущего срока и сбрасывает напоминание
+func (db *DB) ExtendPost(ctx context.Context, id int, userID int64, days int) (time.Time, error) {
+	query := `
+		UPDATE posts
+		SET expires_at = GREATEST(expires_at, NOW()) + make_interval(days => $1),
+		    expiry_reminder_sent_at = NULL
+		WHERE id = $2 AND user_id = $3 AND is_deleted = FALSE AND deletion_status IS NULL
+		RETURNING expires_at`
+	var expiresAt time.Time
+	err := db.Pool.QueryRow(ctx, query, days, id, userID).Scan(&expiresAt)
+	return expiresAt, err
+}
+
 func (db *DB) MarkPostDeleted(ctx context.Context, id int) error {
-	query := `UPDATE posts SET is_deleted = TRUE, deleted_at = NOW() WHERE id = $1`
+	query := `UPDATE posts SET is_deleted = TRUE, deleted_at = NOW(), deletion_status = 'done' WHERE id = $1`
+	_, err := db.Pool.Exec(ctx, query, id)
+	return err
+}
+
+// HasActivePost — у пользователя есть неистёкший платный пост в группе
+func (db *DB) HasActivePost(ctx context.Context, userID, groupID int64) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM posts p
+			JOIN topics t ON t.id = p.topic_id
+			WHERE p.user_id = $1 AND t.group_id = $2
+			  AND p.is_deleted = FALSE AND p.expires_at > NOW()
+		)`
+	var ok bool
+	err := db.Pool.QueryRow(ctx, query, userID, groupID).Scan(&ok)
+	return ok, err
+}
+
+// IncrementPostDeletionAttempts отмечает неудачную попытку удаления и возвращает их число
+func (db *DB) IncrementPostDeletionAttempts(ctx context.Context, id int) (int, error) {
+	query := `
+		UPDATE posts SET deletion_status = 'pending', deletion_attempts = deletion_attempts + 1
+		WHERE id = $1
+		RETURNING deletion_attempts`
+	var attempts int
+	err := db.Pool.QueryRow(ctx, query, id).Scan(&attempts)
+	return attempts, err
+}
+
+// MarkPostDeletionFailed — пост не удалось удалить, автоматических попыток больше не будет
+func (db *DB) MarkPostDeletionFailed(ctx context.Context, id int) error {
+	query := `UPDATE posts SET deletion_status = 'failed' WHERE id = $1`
+	_, err := db.Pool.Exec(ctx, query, id)
+	return err
+}
+
+// ============================================
+// Post Message Deletions
+// ============================================
+
+// GetPostMessageDeletions создаёт недостающие записи о сообщениях поста и возвращает все
+func (db *DB) GetPostMessageDeletions(ctx context.Context, postID int, chatID int64, messageIDs []int) ([]PostMessageDeletion, error) {
+	insert := `
+		INSERT INTO post_message_deletions (post_id, chat_id, message_id)
+		SELECT $1, $2, unnest($3::INTEGER[])
+		ON CONFLICT (post_id, message_id) DO NOTHING`
+	if _, err := db.Pool.Exec(ctx, insert, postID, chatID, messageIDs); err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT post_id, chat_id, message_id, status, attempts, last_error, updated_at
+		FROM post_message_deletions
+		WHERE post_id = $1
+		ORDER BY message_id`
+
+	rows, err := db.Pool.Query(ctx, query, postID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deletions []PostMessageDeletion
+	for rows.Next() {
+		var d PostMessageDeletion
+		if err := rows.Scan(&d.PostID, &d.ChatID, &d.MessageID, &d.Status, &d.Attempts, &d.LastError, &d.UpdatedAt); err != nil {
+			return nil, err
+		}
+		deletions = append(deletions, d)
+	}
+	return deletions, rows.Err()
+}
+
+func (db *DB) UpdatePostMessageDeletion(ctx context.Context, postID, messageID int, status MessageDeletionStatus, lastError *string) error {
+	query := `
+		UPDATE post_message_deletions
+		SET status = $1, last_error = $2, attempts = attempts + 1, updated_at = NOW()
+		WHERE post_id = $3 AND message_id = $4`
+	_, err := db.Pool.Exec(ctx, query, status, lastError, postID, messageID)
+	return err
+}
+
+// ============================================
+// Scheduled Posts (отложенная публикация)
+// ============================================
+
+const scheduledPostColumns = `id, user_id, topic_id, content_text, photo_file_ids, publish_at, status, post_id, created_at, published_at`
+
+func scanScheduledPost(row pgx.Row) (*ScheduledPost, error) {
+	var p ScheduledPost
+	err := row.Scan(
+		&p.ID, &p.UserID, &p.TopicID, &p.ContentText, &p.PhotoFileIDs, &p.PublishAt,
+		&p.Status, &p.PostID, &p.CreatedAt, &p.PublishedAt,
+	)
+	return &p, err
+}
+
+func (db *DB) CreateScheduledPost(ctx context.Context, userID int64, topicID int, text *string, photoIDs []string, publishAt time.Time) (*ScheduledPost, error) {
+	query := `
+		INSERT INTO scheduled_posts (user_id, topic_id, content_text, photo_file_ids, publish_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING ` + scheduledPostColumns
+
+	return scanScheduledPost(db.Pool.QueryRow(ctx, query, userID, topicID, text, photoIDs, publishAt))
+}
+
+func (db *DB) GetScheduledPost(ctx context.Context, id int, userID int64) (*ScheduledPost, error) {
+	query := `SELECT ` + scheduledPostColumns + ` FROM scheduled_posts WHERE id = $1 AND user_id = $2`
+	return scanScheduledPost(db.Pool.QueryRow(ctx, query, id, userID))
+}
+
+func (db *DB) GetUserScheduledPosts(ctx context.Context, userID int64) ([]ScheduledPost, error) {
+	query := `
+		SELECT ` + scheduledPostColumns + `
+		FROM scheduled_posts
+		WHERE user_id = $1 AND status = 'pending'
+		ORDER BY publish_at`
+
+	rows, err := db.Pool.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var posts []ScheduledPost
+	for rows.Next() {
+		p, err := scanScheduledPost(rows)
+		if err != nil {
+			return nil, err
+		}
+		posts = append(posts, *p)
+	}
+	return posts, rows.Err()
+}
+
+// RescheduleScheduledPost переносит ещё не опубликованный пост, false — если переносить нечего
+func (db *DB) RescheduleScheduledPost(ctx context.Context, id int, userID int64, publishAt time.Time) (bool, error) {
+	query := `UPDATE scheduled_posts SET publish_at = $1 WHERE id = $2 AND user_id = $3 AND status = 'pending'`
+	tag, err := db.Pool.Exec(ctx, query, publishAt, id, userID)
+	return tag.RowsAffected() > 0, err
+}
+
+// CancelScheduledPost отменяет ещё не опубликованный пост, false — если отменять нечего
+func (db *DB) CancelScheduledPost(ctx context.Context, id int, userID int64) (bool, error) {
+	query := `UPDATE scheduled_posts SET status = 'cancelled' WHERE id = $1 AND user_id = $2 AND status = 'pending'`
+	tag, err := db.Pool.Exec(ctx, query, id, userID)
+	return tag.RowsAffected() > 0, err
+}
+
+// ClaimScheduledPost забирает пост на публикацию, если его время наступило
+func (db *DB) ClaimScheduledPost(ctx context.Context, id int) (*ScheduledPost, error) {
+	query := `
+		UPDATE scheduled_posts SET status = 'publishing'
+		WHERE id = $1 AND status = 'pending' AND publish_at <= NOW()
+		RETURNING ` + scheduledPostColumns
+
+	return scanScheduledPost(db.Pool.QueryRow(ctx, query, id))
+}
+
+// ReleaseScheduledPost возвращает пост в ожидание для повторной попытки
+func (db *DB) ReleaseScheduledPost(ctx context.Context, id int) error {
+	query := `UPDATE scheduled_posts SET status = 'pending' WHERE id = $1 AND status = 'publishing'`
+	_, err := db.Pool.Exec(ctx, query, id)
+	return err
+}
+
+func (db *DB) MarkScheduledPostPublished(ctx context.Context, id int, postID *int) error {
+	query := `UPDATE scheduled_posts SET status = 'published', post_id = $1, published_at = NOW() WHERE id = $2`
+	_, err := db.Pool.Exec(ctx, query, postID, id)
+	return err
+}
+
+func (db *DB) MarkScheduledPostFailed(ctx context.Context, id int) error {
+	query := `UPDATE scheduled_posts SET status = 'failed' WHERE id = $1`
 	_, err := db.Pool.Exec(ctx, query, id)
 	return err
 }
@@ -254,21 +521,256 @@ func (db *DB) CreatePayment(ctx context.Context, userID int64, topicID int, tele
 // Spam Violations
 // ============================================
 
-func (db *DB) CreateSpamViolation(ctx context.Context, userID, groupID int64, topicID *int, messageText, violationType, matchFound string) error {
+func (db *DB) CreateSpamViolation(ctx context.Context, v *SpamViolation) (int, error) {
 	query := `
-		INSERT INTO spam_violations (user_id, group_id, topic_id, message_text, violation_type, match_found)
-		VALUES ($1, $2, $3, $4, $5, $6)`
-	_, err := db.Pool.Exec(ctx, query, userID, groupID, topicID, messageText, violationType, matchFound)
-	return err
+		INSERT INTO spam_violations (user_id, group_id, topic_id, message_text, violation_type,
+		                             match_found, entity_type, sender_chat_id, via_bot_id,
+		                             score, action, signals, is_shadow, photo_file_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+		RETURNING id`
+	var id int
+	err := db.Pool.QueryRow(ctx, query, v.UserID, v.GroupID, v.TopicID, v.MessageText, v.ViolationType,
+		v.MatchFound, v.EntityType, v.SenderChatID, v.ViaBotID,
+		v.Score, v.Action, v.Signals, v.IsShadow, v.PhotoFileID).Scan(&id)
+	return id, err
+}
+
+func (db *DB) GetSpamViolation(ctx context.Context, id int) (*SpamViolation, error) {
+	query := `
+		SELECT id, user_id, group_id, topic_id, message_text, violation_type, match_found, entity_type,
+		       COALESCE(score, 0), COALESCE(action, ''), signals, is_shadow, photo_file_id, mod_verdict, reviewed_by, reviewed_at,
+		       appeal_status, appealed_at, appeal_resolved_by, appeal_resolved_at, created_at
+		FROM spam_violations WHERE id = $1`
+
+	var v SpamViolation
+	err := db.Pool.QueryRow(ctx, query, id).Scan(
+		&v.ID, &v.UserID, &v.GroupID, &v.TopicID, &v.MessageText, &v.ViolationType, &v.MatchFound, &v.EntityType,
+		&v.Score, &v.Action, &v.Signals, &v.IsShadow, &v.PhotoFileID, &v.ModVerdict, &v.ReviewedBy, &v.ReviewedAt,
+		&v.AppealStatus, &v.AppealedAt, &v.AppealResolvedBy, &v.AppealResolvedAt, &v.CreatedAt,
+	)
+	return &v, err
+}
+
+// CreateAppeal отмечает апелляцию по нарушению пользователя.
+// false — нарушения нет, оно чужое или апелляция уже подана.
+func (db *DB) CreateAppeal(ctx context.Context, id int, userID int64) (bool, error) {
+	query := `
+		UPDATE spam_violations SET appeal_status = 'pending', appealed_at = NOW()
+		WHERE id = $1 AND user_id = $2 AND appeal_status IS NULL AND NOT is_shadow`
+	tag, err := db.Pool.Exec(ctx, query, id, userID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// ResolveAppeal сохраняет решение по апелляции. false — апелляция уже рассмотрена.
+func (db *DB) ResolveAppeal(ctx context.Context, id int, status AppealStatus, moderatorID int64) (bool, error) {
+	query := `
+		UPDATE spam_violations
+		SET appeal_status = $2, appeal_resolved_by = $3, appeal_resolved_at = NOW()
+		WHERE id = $1 AND appeal_status = 'pending'`
+	tag, err := db.Pool.Exec(ctx, query, id, status, moderatorID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// GetUserGroupViolationsCount — число нарушений пользователя в группе
+// с момента since, оставшихся в силе (без теневых, предупреждений
+// и отменённых модератором или по апелляции)
+func (db *DB) GetUserGroupViolationsCount(ctx context.Context, userID, groupID int64, since time.Time) (int, error) {
+	query := `
+		SELECT COUNT(*) FROM spam_violations v
+		WHERE v.user_id = $1 AND v.group_id = $2 AND v.created_at > $3
+		  AND (v.action IS NULL OR v.action = 'delete') AND` + violationStands
+	var count int
+	err := db.Pool.QueryRow(ctx, query, userID, groupID, since).Scan(&count)
+	return count, err
+}
+
+// SetSpamViolationVerdict сохраняет вердикт модератора. false — нарушения нет.
+func (db *DB) SetSpamViolationVerdict(ctx context.Context, id int, verdict string, reviewerID int64) (bool, error) {
+	query := `
+		UPDATE spam_violations
+		SET mod_verdict = $2, reviewed_by = $3, reviewed_at = NOW()
+		WHERE id = $1`
+	tag, err := db.Pool.Exec(ctx, query, id, verdict, reviewerID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// GetShadowStatsByGroup — теневые срабатывания по группам с момента since
+func (db *DB) GetShadowStatsByGroup(ctx context.Context, since time.Time) ([]ShadowStats, error) {
+	query := `
+		SELECT group_id, 0,
+		       COUNT(*),
+		       COUNT(*) FILTER (WHERE mod_verdict = 'spam'),
+		       COUNT(*) FILTER (WHERE mod_verdict = 'ham')
+		FROM spam_violations
+		WHERE is_shadow AND created_at > $1
+		GROUP BY group_id
+		ORDER BY group_id`
+	return db.queryShadowStats(ctx, query, since)
+}
+
+// GetShadowStatsByRule — теневые срабатывания по правилам с момента since
+func (db *DB) GetShadowStatsByRule(ctx context.Context, since time.Time) ([]ShadowStats, error) {
+	query := `
+		SELECT 0, (s->>'rule_id')::int,
+		       COUNT(*),
+		       COUNT(*) FILTER (WHERE v.mod_verdict = 'spam'),
+		       COUNT(*) FILTER (WHERE v.mod_verdict = 'ham')
+		FROM spam_violations v, jsonb_array_elements(v.signals) s
+		WHERE v.is_shadow AND v.created_at > $1 AND s->>'shadow' = 'true'
+		GROUP BY 2
+		ORDER BY 2`
+	return db.queryShadowStats(ctx, query, since)
+}
+
+func (db *DB) queryShadowStats(ctx context.Context, query string, since time.Time) ([]ShadowStats, error) {
+	rows, err := db.Pool.Query(ctx, query, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stats []ShadowStats
+	for rows.Next() {
+		var st ShadowStats
+		if err := rows.Scan(&st.GroupID, &st.RuleID, &st.Hits, &st.Confirmed, &st.Rejected); err != nil {
+			return nil, err
+		}
+		stats = append(stats, st)
+	}
+	return stats, rows.Err()
 }
 
+// GetUserViolationsCount — нарушения пользователя во всех группах, оставшиеся в силе
 func (db *DB) GetUserViolationsCount(ctx context.Context, userID int64, since time.Time) (int, error) {
-	query := `SELECT COUNT(*) FROM spam_violations WHERE user_id = $1 AND created_at > $2`
+	// Задержанные сообщения новичков считаются, только если модератор их отклонил
+	query := `
+		SELECT COUNT(*) FROM spam_violations v
+		WHERE v.user_id = $1 AND v.created_at > $2
+		  AND (v.action IS DISTINCT FROM 'hold' OR v.mod_verdict = 'spam') AND` + violationStands
 	var count int
 	err := db.Pool.QueryRow(ctx, query, userID, since).Scan(&count)
 	return count, err
 }
 
+// ============================================
+// Jobs (очередь задач)
+// ============================================
+
+// EnqueueJob ставит задачу в очередь. Если задача с таким unique_key
+// уже ожидает выполнения — обновляет её время и payload.
+func (db *DB) EnqueueJob(ctx context.Context, jobType string, uniqueKey *string, payload []byte, runAt time.Time, maxAttempts int) error {
+	query := `
+		INSERT INTO jobs (type, unique_key, payload, run_at, max_attempts)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (unique_key) WHERE status = 'pending'
+		DO UPDATE SET run_at = EXCLUDED.run_at, payload = EXCLUDED.payload`
+	_, err := db.Pool.Exec(ctx, query, jobType, uniqueKey, payload, runAt, maxAttempts)
+	return err
+}
+
+// EnqueueJobOnce ставит задачу, только если задачи с таким unique_key
+// ещё нет в ожидании: время ожидающей задачи не переносится
+func (db *DB) EnqueueJobOnce(ctx context.Context, jobType string, uniqueKey string, payload []byte, runAt time.Time, maxAttempts int) error {
+	query := `
+		INSERT INTO jobs (type, unique_key, payload, run_at, max_attempts)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (unique_key) WHERE status = 'pending' DO NOTHING`
+	_, err := db.Pool.Exec(ctx, query, jobType, uniqueKey, payload, runAt, maxAttempts)
+	return err
+}
+
+// ClaimJobs забирает задачи, время которых наступило. Задачи, зависшие
+// в running дольше staleAfter (процесс упал), забираются повторно.
+func (db *DB) ClaimJobs(ctx context.Context, limit int, staleAfter time.Duration) ([]Job, error) {
+	query := `
+		UPDATE jobs SET status = 'running', locked_at = NOW(), attempts = attempts + 1
+		WHERE id IN (
+			SELECT id FROM jobs
+			WHERE (status = 'pending' AND run_at <= NOW())
+			   OR (status = 'running' AND locked_at < NOW() - $2 * INTERVAL '1 second')
+			ORDER BY run_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, type, payload, unique_key, run_at, status, attempts, max_attempts, last_error, created_at`
+
+	rows, err := db.Pool.Query(ctx, query, limit, staleAfter.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jobs []Job
+	for rows.Next() {
+		var j Job
+		if err := rows.Scan(&j.ID, &j.Type, &j.Payload, &j.UniqueKey, &j.RunAt, &j.Status,
+			&j.Attempts, &j.MaxAttempts, &j.LastError, &j.CreatedAt); err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, j)
+	}
+	return jobs, rows.Err()
+}
+
+func (db *DB) CompleteJob(ctx context.Context, id int64) error {
+	query := `UPDATE jobs SET status = 'done', locked_at = NULL, finished_at = NOW() WHERE id = $1`
+	_, err := db.Pool.Exec(ctx, query, id)
+	return err
+}
+
+// RetryJob возвращает задачу в ожидание. Если пока она выполнялась, поставлена
+// новая задача с тем же unique_key, повторять старую незачем: она помечается
+// failed, и возвращается false.
+func (db *DB) RetryJob(ctx context.Context, id int64, runAt time.Time, lastError string) (bool, error) {
+	query := `
+		UPDATE jobs SET status = 'pending', locked_at = NULL, run_at = $1, last_error = $2
+		WHERE id = $3 AND NOT EXISTS (
+			SELECT 1 FROM jobs p WHERE p.unique_key = jobs.unique_key AND p.status = 'pending'
+		)`
+	tag, err := db.Pool.Exec(ctx, query, runAt, lastError, id)
+	var pgErr *pgconn.PgError
+	switch {
+	case errors.As(err, &pgErr) && pgErr.Code == "23505":
+		// Новую задачу поставили одновременно с проверкой
+	case err != nil:
+		return false, err
+	case tag.RowsAffected() > 0:
+		return true, nil
+	}
+	return false, db.FailJob(ctx, id, lastError)
+}
+
+func (db *DB) FailJob(ctx context.Context, id int64, lastError string) error {
+	query := `UPDATE jobs SET status = 'failed', locked_at = NULL, last_error = $1, finished_at = NOW() WHERE id = $2`
+	_, err := db.Pool.Exec(ctx, query, lastError, id)
+	return err
+}
+
+// ReloadChannel — канал NOTIFY, по которому все инстансы перечитывают
+// белый список ссылок, правила спама и модель классификатора
+const ReloadChannel = "moderation_reload"
+
+func (db *DB) NotifyReload(ctx context.Context) error {
+	_, err := db.Pool.Exec(ctx, `SELECT pg_notify($1, '')`, ReloadChannel)
+	return err
+}
+
+// DeleteFinishedJobs удаляет выполненные задачи старше before
+func (db *DB) DeleteFinishedJobs(ctx context.Context, before time.Time) (int64, error) {
+	query := `DELETE FROM jobs WHERE status = 'done' AND finished_at < $1`
+	tag, err := db.Pool.Exec(ctx, query, before)
+	return tag.RowsAffected(), err
+}
+
 // ============================================
 // Allowed Domains
 // ============================================
@@ -303,3 +805,511 @@ func (db *DB) RemoveAllowedDomain(ctx context.Context, domain string) error {
 	_, err := db.Pool.Exec(ctx, query, domain)
 	return err
 }
+
+// ============================================
+// Spam Rules (правила детектора)
+// ============================================
+
+const spamRuleColumns = `id, group_id, name, kind, pattern, weight, action, is_enabled, is_shadow, created_at`
+
+func scanSpamRule(row pgx.Row) (*SpamRule, error) {
+	var r SpamRule
+	err := row.Scan(
+		&r.ID, &r.GroupID, &r.Name, &r.Kind, &r.Pattern, &r.Weight, &r.Action, &r.IsEnabled, &r.IsShadow, &r.CreatedAt,
+	)
+	return &r, err
+}
+
+// GetSpamRules возвращает правила; onlyEnabled — только включённые
+func (db *DB) GetSpamRules(ctx context.Context, onlyEnabled bool) ([]SpamRule, error) {
+	query := `
+		SELECT ` + spamRuleColumns + `
+		FROM spam_rules
+		WHERE is_enabled OR NOT $1
+		ORDER BY id`
+
+	rows, err := db.Pool.Query(ctx, query, onlyEnabled)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []SpamRule
+	for rows.Next() {
+		r, err := scanSpamRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, *r)
+	}
+	return rules, rows.Err()
+}
+
+func (db *DB) GetSpamRule(ctx context.Context, id int) (*SpamRule, error) {
+	query := `SELECT ` + spamRuleColumns + ` FROM spam_rules WHERE id = $1`
+	return scanSpamRule(db.Pool.QueryRow(ctx, query, id))
+}
+
+// SetSpamRuleEnabled включает или выключает правило. false — правила нет.
+func (db *DB) SetSpamRuleEnabled(ctx context.Context, id int, enabled bool) (bool, error) {
+	query := `UPDATE spam_rules SET is_enabled = $2 WHERE id = $1`
+	tag, err := db.Pool.Exec(ctx, query, id, enabled)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// ============================================
+// Sanctions (эскалация санкций)
+// ============================================
+
+// GetSanctionSteps возвращает ступени группы, а если своих нет — ступени
+// по умолчанию. Сортировка от самой строгой (большее число нарушений).
+func (db *DB) GetSanctionSteps(ctx context.Context, groupID int64) ([]SanctionStep, error) {
+	query := `
+		SELECT id, group_id, violations, window_hours, action, duration_minutes
+		FROM sanction_steps
+		WHERE group_id = $1
+		   OR (group_id IS NULL AND NOT EXISTS (SELECT 1 FROM sanction_steps WHERE group_id = $1))
+		ORDER BY violations DESC`
+
+	rows, err := db.Pool.Query(ctx, query, groupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var steps []SanctionStep
+	for rows.Next() {
+		var st SanctionStep
+		if err := rows.Scan(&st.ID, &st.GroupID, &st.Violations, &st.WindowHours, &st.Action, &st.DurationMinutes); err != nil {
+			return nil, err
+		}
+		steps = append(steps, st)
+	}
+	return steps, rows.Err()
+}
+
+func (db *DB) CreateSanction(ctx context.Context, s *Sanction) (int, error) {
+	query := `
+		INSERT INTO sanctions (user_id, group_id, action, until, violation_id, reason)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id`
+	var id int
+	err := db.Pool.QueryRow(ctx, query, s.UserID, s.GroupID, s.Action, s.Until, s.ViolationID, s.Reason).Scan(&id)
+	return id, err
+}
+
+// GetActiveSanctions — действующие санкции пользователя указанного типа во всех группах
+func (db *DB) GetActiveSanctions(ctx context.Context, userID int64, action SanctionAction) ([]Sanction, error) {
+	query := `
+		SELECT id, user_id, group_id, action, until, violation_id, reason, created_at, lifted_at, lifted_by
+		FROM sanctions
+		WHERE user_id = $1 AND action = $2 AND lifted_at IS NULL
+		  AND (until IS NULL OR until > NOW())
+		ORDER BY created_at`
+
+	rows, err := db.Pool.Query(ctx, query, userID, action)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sanctions []Sanction
+	for rows.Next() {
+		var s Sanction
+		if err := rows.Scan(&s.ID, &s.UserID, &s.GroupID, &s.Action, &s.Until, &s.ViolationID,
+			&s.Reason, &s.CreatedAt, &s.LiftedAt, &s.LiftedBy); err != nil {
+			return nil, err
+		}
+		sanctions = append(sanctions, s)
+	}
+	return sanctions, rows.Err()
+}
+
+func (db *DB) LiftSanction(ctx context.Context, id int, liftedBy int64) error {
+	query := `UPDATE sanctions SET lifted_at = NOW(), lifted_by = $2 WHERE id = $1 AND lifted_at IS NULL`
+	_, err := db.Pool.Exec(ctx, query, id, liftedBy)
+	return err
+}
+
+// ============================================
+// Trusted Members (исключения из проверки на спам)
+// ============================================
+
+func (db *DB) IsTrustedMember(ctx context.Context, userID, groupID int64) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM trusted_members
+			WHERE user_id = $1 AND (group_id IS NULL OR group_id = $2)
+		)`
+	var ok bool
+	err := db.Pool.QueryRow(ctx, query, userID, groupID).Scan(&ok)
+	return ok, err
+}
+
+func (db *DB) AddTrustedMember(ctx context.Context, m *TrustedMember) error {
+	query := `
+		INSERT INTO trusted_members (user_id, group_id, note, added_by)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id, group_id) DO UPDATE SET note = EXCLUDED.note, added_by = EXCLUDED.added_by`
+	_, err := db.Pool.Exec(ctx, query, m.UserID, m.GroupID, m.Note, m.AddedBy)
+	return err
+}
+
+// RemoveTrustedMember удаляет запись; false — такой записи не было
+func (db *DB) RemoveTrustedMember(ctx context.Context, userID int64, groupID *int64) (bool, error) {
+	query := `DELETE FROM trusted_members WHERE user_id = $1 AND group_id IS NOT DISTINCT FROM $2`
+	tag, err := db.Pool.Exec(ctx, query, userID, groupID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+func (db *DB) GetTrustedMembers(ctx context.Context) ([]TrustedMember, error) {
+	query := `
+		SELECT id, user_id, group_id, note, added_by, created_at
+		FROM trusted_members
+		ORDER BY group_id NULLS FIRST, user_id`
+
+	rows, err := db.Pool.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var members []TrustedMember
+	for rows.Next() {
+		var m TrustedMember
+		if err := rows.Scan(&m.ID, &m.UserID, &m.GroupID, &m.Note, &m.AddedBy, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		members = append(members, m)
+	}
+	return members, rows.Err()
+}
+
+// ============================================
+// Group Members (первые сообщения участников)
+// ============================================
+
+// Нарушение в силе: не теневое и не отменено модератором или по апелляции
+const violationStands = `
+	  NOT v.is_shadow
+	  AND v.mod_verdict IS DISTINCT FROM 'ham'
+	  AND v.appeal_status IS DISTINCT FROM 'restored'
+	  AND v.appeal_status IS DISTINCT FROM 'whitelisted'`
+
+// Нарушения участника в группе, кроме теневых и отменённых модератором
+// (задержанное сообщение новичка в счёт, пока его не одобрили)
+const countedViolations = `
+	SELECT COUNT(*) FROM spam_violations v
+	WHERE v.user_id = m.user_id AND v.group_id = m.group_id AND` + violationStands
+
+func scanGroupMember(row pgx.Row) (*GroupMember, error) {
+	var m GroupMember
+	err := row.Scan(&m.UserID, &m.GroupID, &m.FirstSeenAt, &m.MessageCount, &m.LastMessageAt, &m.Violations)
+	return &m, err
+}
+
+// RecordGroupMessage учитывает сообщение участника. При первом сообщении
+// участник добавляется; если он уже знаком боту как покупатель, временем
+// появления считается регистрация в боте.
+func (db *DB) RecordGroupMessage(ctx context.Context, userID, groupID int64) (*GroupMember, error) {
+	query := `
+		INSERT INTO group_members AS m (user_id, group_id, first_seen_at, message_count, last_message_at)
+		VALUES ($1, $2, LEAST(NOW(), (SELECT created_at FROM users WHERE id = $1)), 1, NOW())
+		ON CONFLICT (user_id, group_id) DO UPDATE
+		SET message_count = m.message_count + 1, last_message_at = NOW()
+		RETURNING user_id, group_id, first_seen_at, message_count, last_message_at, (` + countedViolations + `)`
+	return scanGroupMember(db.Pool.QueryRow(ctx, query, userID, groupID))
+}
+
+func (db *DB) GetGroupMember(ctx context.Context, userID, groupID int64) (*GroupMember, error) {
+	query := `
+		SELECT user_id, group_id, first_seen_at, message_count, last_message_at, (` + countedViolations + `)
+		FROM group_members m
+		WHERE user_id = $1 AND group_id = $2`
+	return scanGroupMember(db.Pool.QueryRow(ctx, query, userID, groupID))
+}
+
+// ResolveHeldMessage сохраняет решение модератора по задержанному сообщению.
+// false — решение уже принято.
+func (db *DB) ResolveHeldMessage(ctx context.Context, id int, verdict string, reviewerID int64) (bool, error) {
+	query := `
+		UPDATE spam_violations
+		SET mod_verdict = $2, reviewed_by = $3, reviewed_at = NOW()
+		WHERE id = $1 AND mod_verdict IS NULL`
+	tag, err := db.Pool.Exec(ctx, query, id, verdict, reviewerID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// ============================================
+// Member Verifications (капча для новых участников)
+// ============================================
+
+const memberVerificationColumns = `id, user_id, group_id, status, answer, message_id, expires_at, created_at, resolved_at`
+
+func scanMemberVerification(row pgx.Row) (*MemberVerification, error) {
+	var v MemberVerification
+	err := row.Scan(&v.ID, &v.UserID, &v.GroupID, &v.Status, &v.Answer, &v.MessageID, &v.ExpiresAt, &v.CreatedAt, &v.ResolvedAt)
+	return &v, err
+}
+
+func (db *DB) GetMemberVerification(ctx context.Context, id int) (*MemberVerification, error) {
+	query := `SELECT ` + memberVerificationColumns + ` FROM member_verifications WHERE id = $1`
+	return scanMemberVerification(db.Pool.QueryRow(ctx, query, id))
+}
+
+// IsMemberVerified — пользователь уже проходил капчу в группе
+func (db *DB) IsMemberVerified(ctx context.Context, userID, groupID int64) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM member_verifications
+			WHERE user_id = $1 AND group_id = $2 AND status = 'passed'
+		)`
+	var ok bool
+	err := db.Pool.QueryRow(ctx, query, userID, groupID).Scan(&ok)
+	return ok, err
+}
+
+// StartMemberVerification создаёт проверку или перезапускает прежнюю
+// (участник не прошёл капчу и вошёл снова)
+func (db *DB) StartMemberVerification(ctx context.Context, userID, groupID int64, answer int, expiresAt time.Time) (*MemberVerification, error) {
+	query := `
+		INSERT INTO member_verifications (user_id, group_id, answer, expires_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id, group_id) DO UPDATE
+		SET status = 'pending', answer = EXCLUDED.answer, message_id = NULL,
+		    expires_at = EXCLUDED.expires_at, created_at = NOW(), resolved_at = NULL
+		RETURNING ` + memberVerificationColumns
+	return scanMemberVerification(db.Pool.QueryRow(ctx, query, userID, groupID, answer, expiresAt))
+}
+
+func (db *DB) SetMemberVerificationMessage(ctx context.Context, id, messageID int) error {
+	query := `UPDATE member_verifications SET message_id = $2 WHERE id = $1`
+	_, err := db.Pool.Exec(ctx, query, id, messageID)
+	return err
+}
+
+// ResolveMemberVerification завершает проверку. false — она уже завершена.
+func (db *DB) ResolveMemberVerification(ctx context.Context, id int, status VerificationStatus) (bool, error) {
+	query := `
+		UPDATE member_verifications SET status = $2, resolved_at = NOW()
+		WHERE id = $1 AND status = 'pending'`
+	tag, err := db.Pool.Exec(ctx, query, id, status)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// ============================================
+// Bayes (модель классификатора спама)
+// ============================================
+
+func (db *DB) GetBayesTokens(ctx context.Context) ([]BayesToken, error) {
+	rows, err := db.Pool.Query(ctx, `SELECT token, spam_count, ham_count FROM bayes_tokens`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []BayesToken
+	for rows.Next() {
+		var t BayesToken
+		if err := rows.Scan(&t.Token, &t.SpamCount, &t.HamCount); err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, t)
+	}
+	return tokens, rows.Err()
+}
+
+// GetBayesTrainingSet — размеченные нарушения. Решение модератора (вердикт,
+// апелляция) важнее автоматического: ham — одобренные и восстановленные
+// сообщения, spam — отклонённые модератором и удалённые по оценке детектора.
+// Удалённые политикой группы и флуд-контролем (оценка 0) не учитываются:
+// их удалили не за текст.
+func (db *DB) GetBayesTrainingSet(ctx context.Context) ([]BayesSample, error) {
+	query := `
+		SELECT id, message_text, label FROM (
+			SELECT id, message_text,
+			       CASE
+			           WHEN mod_verdict = 'ham' OR appeal_status IN ('restored', 'whitelisted') THEN 'ham'
+			           WHEN mod_verdict = 'spam' THEN 'spam'
+			           WHEN NOT is_shadow AND action = 'delete' AND score > 0
+			                AND appeal_status IS DISTINCT FROM 'pending' THEN 'spam'
+			       END AS label
+			FROM spam_violations
+			WHERE message_text <> ''
+		) s
+		WHERE label IS NOT NULL
+		ORDER BY id`
+
+	rows, err := db.Pool.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var samples []BayesSample
+	for rows.Next() {
+		var s BayesSample
+		if err := rows.Scan(&s.ViolationID, &s.Text, &s.Label); err != nil {
+			return nil, err
+		}
+		samples = append(samples, s)
+	}
+	return samples, rows.Err()
+}
+
+// ReplaceBayesModel заменяет модель целиком и отмечает, какие нарушения
+// в неё вошли (labels: id нарушения → spam / ham)
+func (db *DB) ReplaceBayesModel(ctx context.Context, tokens []BayesToken, labels map[int]string) error {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `TRUNCATE bayes_tokens`); err != nil {
+		return err
+	}
+	_, err = tx.CopyFrom(ctx, pgx.Identifier{"bayes_tokens"}, []string{"token", "spam_count", "ham_count"},
+		pgx.CopyFromSlice(len(tokens), func(i int) ([]any, error) {
+			return []any{tokens[i].Token, tokens[i].SpamCount, tokens[i].HamCount}, nil
+		}))
+	if err != nil {
+		return err
+	}
+
+	var spam, ham []int
+	for id, label := range labels {
+		if label == ModVerdictSpam {
+			spam = append(spam, id)
+		} else {
+			ham = append(ham, id)
+		}
+	}
+	query := `
+		UPDATE spam_violations
+		SET bayes_label = CASE WHEN id = ANY($1) THEN 'spam' WHEN id = ANY($2) THEN 'ham' END
+		WHERE bayes_label IS NOT NULL OR id = ANY($1) OR id = ANY($2)`
+	if _, err := tx.Exec(ctx, query, spam, ham); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// LearnBayes дообучает модель на нарушении с решением модератора. Если
+// нарушение уже учтено с другим классом, прежний учёт отменяется.
+// Возвращает прежний класс ("" — не был учтён) и false, если класс не изменился.
+func (db *DB) LearnBayes(ctx context.Context, violationID int, tokens []string, label string) (string, bool, error) {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return "", false, err
+	}
+	defer tx.Rollback(ctx)
+
+	var prev string
+	err = tx.QueryRow(ctx, `SELECT COALESCE(bayes_label, '') FROM spam_violations WHERE id = $1 FOR UPDATE`,
+		violationID).Scan(&prev)
+	if err != nil || prev == label {
+		return prev, false, err
+	}
+
+	// Пустой токен — счётчик сообщений
+	tokens = append([]string{""}, tokens...)
+	if prev != "" {
+		if err := addBayesCounts(ctx, tx, tokens, prev, -1); err != nil {
+			return prev, false, err
+		}
+	}
+	if err := addBayesCounts(ctx, tx, tokens, label, 1); err != nil {
+		return prev, false, err
+	}
+
+	if _, err := tx.Exec(ctx, `UPDATE spam_violations SET bayes_label = $2 WHERE id = $1`, violationID, label); err != nil {
+		return prev, false, err
+	}
+	return prev, true, tx.Commit(ctx)
+}
+
+func addBayesCounts(ctx context.Context, tx pgx.Tx, tokens []string, label string, delta int) error {
+	spam, ham := 0, delta
+	if label == ModVerdictSpam {
+		spam, ham = delta, 0
+	}
+	query := `
+		INSERT INTO bayes_tokens AS b (token, spam_count, ham_count)
+		SELECT t, GREATEST($2, 0), GREATEST($3, 0) FROM unnest($1::text[]) AS t
+		ON CONFLICT (token) DO UPDATE
+		SET spam_count = GREATEST(b.spam_count + $2, 0), ham_count = GREATEST(b.ham_count + $3, 0)`
+	if _, err := tx.Exec(ctx, query, tokens, spam, ham); err != nil {
+		return err
+	}
+	_, err := tx.Exec(ctx, `
+		DELETE FROM bayes_tokens
+		WHERE token = ANY($1) AND token <> '' AND spam_count = 0 AND ham_count = 0`, tokens)
+	return err
+}
+
+// ============================================
+// Photo hashes (повторы фото)
+// ============================================
+
+// GetPhotoHash — ранее посчитанный хэш того же файла
+func (db *DB) GetPhotoHash(ctx context.Context, fileUniqueID string) (uint64, error) {
+	var hash int64
+	query := `SELECT hash FROM photo_hashes WHERE file_unique_id = $1 LIMIT 1`
+	err := db.Pool.QueryRow(ctx, query, fileUniqueID).Scan(&hash)
+	return uint64(hash), err
+}
+
+func (db *DB) SavePhotoHash(ctx context.Context, p *PhotoHash) error {
+	query := `
+		INSERT INTO photo_hashes (file_unique_id, hash, user_id, chat_id, thread_id, message_id, post_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	_, err := db.Pool.Exec(ctx, query, p.FileUniqueID, int64(p.Hash), p.UserID, p.ChatID, p.ThreadID, p.MessageID, p.PostID)
+	return err
+}
+
+// FindSimilarPhotos — самые ранние фото других пользователей в группе после since,
+// хэш которых отличается от hash не больше чем на maxDistance бит
+func (db *DB) FindSimilarPhotos(ctx context.Context, hash uint64, userID, chatID int64, since time.Time, maxDistance, limit int) ([]PhotoHash, error) {
+	query := `
+		SELECT * FROM (
+			SELECT DISTINCT ON (user_id) id, file_unique_id, hash, user_id, chat_id, thread_id, message_id,
+			       post_id, created_at, bit_count((hash # $1)::bit(64))::int AS distance
+			FROM photo_hashes
+			WHERE chat_id = $3 AND created_at > $4 AND user_id <> $2
+			  AND bit_count((hash # $1)::bit(64)) <= $5
+			ORDER BY user_id, created_at
+		) p
+		ORDER BY created_at
+		LIMIT $6`
+	rows, err := db.Pool.Query(ctx, query, int64(hash), userID, chatID, since, maxDistance, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var photos []PhotoHash
+	for rows.Next() {
+		var p PhotoHash
+		var h int64
+		if err := rows.Scan(&p.ID, &p.FileUniqueID, &h, &p.UserID, &p.ChatID, &p.ThreadID, &p.MessageID,
+			&p.PostID, &p.CreatedAt, &p.Distance); err != nil {
+			return nil, err
+		}
+		p.Hash = uint64(h)
+		photos = append(photos, p)
+	}
+	return photos, rows.Err()
+}
@@ -0,0 +1,380 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go_payment_bot/database"
+	"go_payment_bot/messages"
+	"go_payment_bot/moderation"
+	"go_payment_bot/tglog"
+
+	"github.com/go-telegram/bot/models"
+)
+
+func (h *Handler) isAdmin(userID int64) bool {
+	return slices.Contains(h.cfg.AdminIDs, userID)
+}
+
+// onAdminCommand обрабатывает команды администраторов в личке.
+// Возвращает false, если сообщение не команда администратора.
+func (h *Handler) onAdminCommand(ctx context.Context, msg *models.Message) bool {
+	command, args := splitCommand(msg.Text)
+
+	switch command {
+	case "/rules":
+		h.sendRulesList(ctx, msg.From.ID)
+	case "/testrule":
+		h.testRule(ctx, msg.From.ID, args)
+	case "/enablerule":
+		h.toggleRule(ctx, msg.From, args, true)
+	case "/disablerule":
+		h.toggleRule(ctx, msg.From, args, false)
+	case "/unmute":
+		h.liftSanctions(ctx, msg.From, args, database.SanctionMute)
+	case "/unban":
+		h.liftSanctions(ctx, msg.From, args, database.SanctionBan)
+	case "/shadowreport":
+		h.sendShadowReport(ctx, msg.From.ID, args)
+	case "/trust":
+		h.trustMember(ctx, msg.From, args)
+	case "/untrust":
+		h.untrustMember(ctx, msg.From, args)
+	case "/trusted":
+		h.sendTrustedList(ctx, msg.From.ID)
+	default:
+		return false
+	}
+	return true
+}
+
+// splitCommand отделяет команду от аргументов: "/testrule 5\nтекст" → "/testrule", "5\nтекст"
+func splitCommand(text string) (string, string) {
+	text = strings.TrimSpace(text)
+	i := strings.IndexFunc(text, func(r rune) bool { return r == ' ' || r == '\n' })
+	if i < 0 {
+		return text, ""
+	}
+	return text[:i], strings.TrimSpace(text[i:])
+}
+
+func (h *Handler) sendRulesList(ctx context.Context, userID int64) {
+	rules, err := h.db.GetSpamRules(ctx, false)
+	if err != nil {
+		log.Printf("Ошибка получения правил: %v", err)
+		h.send(ctx, userID, messages.MsgError)
+		return
+	}
+	if len(rules) == 0 {
+		h.send(ctx, userID, messages.MsgRulesEmpty)
+		return
+	}
+
+	var b strings.Builder
+	b.WriteString("📋 Правила спама:\n")
+	for _, r := range rules {
+		b.WriteString("\n" + formatRule(&r))
+	}
+	h.send(ctx, userID, b.String())
+}
+
+func formatRule(r *database.SpamRule) string {
+	state := "⚪️"
+	if r.IsEnabled {
+		state = "🟢"
+	}
+	scope := "все группы"
+	if r.GroupID != nil {
+		scope = fmt.Sprintf("группа %d", *r.GroupID)
+	}
+	line := fmt.Sprintf("%s #%d «%s» — %s, %s, вес %d", state, r.ID, r.Name, r.Kind, scope, r.Weight)
+	if r.Action != nil {
+		line += ", действие " + *r.Action
+	}
+	if r.IsShadow {
+		line += " 👻"
+	}
+	return line
+}
+
+// testRule проверяет правило (в том числе выключенное) на тексте и показывает,
+// как было бы оценено сообщение вместе с остальными сигналами
+func (h *Handler) testRule(ctx context.Context, userID int64, args string) {
+	idStr, sample, _ := strings.Cut(args, "\n")
+	idStr, rest, _ := strings.Cut(strings.TrimSpace(idStr), " ")
+	if rest != "" {
+		// Текст на той же строке: /testrule 5 текст
+		sample = strings.TrimSpace(rest + "\n" + sample)
+	}
+	id, err := strconv.Atoi(idStr)
+	sample = strings.TrimSpace(sample)
+	if err != nil || sample == "" {
+		h.send(ctx, userID, messages.MsgTestRuleUsage)
+		return
+	}
+
+	r, err := h.db.GetSpamRule(ctx, id)
+	if isNotFound(err) {
+		h.send(ctx, userID, messages.MsgRuleNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Ошибка получения правила %d: %v", id, err)
+		h.send(ctx, userID, messages.MsgError)
+		return
+	}
+
+	rule := toModerationRule(r)
+	if err := rule.Compile(); err != nil {
+		h.send(ctx, userID, fmt.Sprintf("%s\n\n❌ Правило не компилируется: %v", formatRule(r), err))
+		return
+	}
+
+	var b strings.Builder
+	b.WriteString(formatRule(r) + "\n\n")
+
+	match := rule.Match(sample)
+	if match == "" {
+		b.WriteString("❌ Правило не сработало.")
+	} else {
+		fmt.Fprintf(&b, "✅ Сработало: «%s»\n\n", match)
+
+		// Оценка как в группе: встроенный детектор, включённые правила и проверяемое
+		signals := moderation.Analyze(sample, h.currentAllowlist())
+		for _, s := range h.currentRules().Apply(rule.GroupID, sample) {
+			if s.RuleID != rule.ID {
+				signals = append(signals, s)
+			}
+		}
+		signals = append(signals, rule.Signal(match))
+
+		thresholds, _ := h.spamSettings(ctx, rule.GroupID)
+		verdict := moderation.Evaluate(signals, thresholds)
+		action := string(verdict.Action)
+		if action == "" {
+			action = "нет"
+		}
+		fmt.Fprintf(&b, "Оценка (без сигналов об авторе): %s\nДействие: %s", verdict.Explain(), action)
+		if verdict.ShadowAction != moderation.ActionNone {
+			fmt.Fprintf(&b, "\nВ теневом режиме: %s", verdict.ShadowAction)
+		}
+	}
+
+	h.send(ctx, userID, b.String())
+}
+
+func (h *Handler) toggleRule(ctx context.Context, from *models.User, args string, enabled bool) {
+	id, err := strconv.Atoi(strings.TrimSpace(args))
+	if err != nil {
+		h.send(ctx, from.ID, messages.MsgRuleUsage)
+		return
+	}
+
+	if enabled {
+		// Не включаем правило, которое детектор всё равно пропустит
+		r, err := h.db.GetSpamRule(ctx, id)
+		if isNotFound(err) {
+			h.send(ctx, from.ID, messages.MsgRuleNotFound)
+			return
+		}
+		if err != nil {
+			h.send(ctx, from.ID, messages.MsgError)
+			return
+		}
+		if err := toModerationRule(r).Compile(); err != nil {
+			h.send(ctx, from.ID, fmt.Sprintf("❌ Правило не компилируется: %v", err))
+			return
+		}
+	}
+
+	found, err := h.db.SetSpamRuleEnabled(ctx, id, enabled)
+	if err != nil {
+		log.Printf("Ошибка изменения правила %d: %v", id, err)
+		h.send(ctx, from.ID, messages.MsgError)
+		return
+	}
+	if !found {
+		h.send(ctx, from.ID, messages.MsgRuleNotFound)
+		return
+	}
+
+	h.LoadSpamRules(ctx)
+	h.notifyReload(ctx)
+	h.send(ctx, from.ID, messages.FormatRuleToggled(id, enabled))
+
+	verb := "выключил"
+	if enabled {
+		verb = "включил"
+	}
+	tglog.Send("🧩 %s (id: %d) %s правило спама #%d", html.EscapeString(from.FirstName), from.ID, verb, id)
+}
+
+const defaultShadowReportDays = 7
+
+// sendShadowReport сравнивает теневые срабатывания с решениями модераторов
+// по группам и правилам: /shadowreport [дней]
+func (h *Handler) sendShadowReport(ctx context.Context, userID int64, args string) {
+	days := defaultShadowReportDays
+	if args != "" {
+		n, err := strconv.Atoi(args)
+		if err != nil || n <= 0 {
+			h.send(ctx, userID, messages.MsgShadowReportUsage)
+			return
+		}
+		days = n
+	}
+	since := time.Now().AddDate(0, 0, -days)
+
+	byGroup, err := h.db.GetShadowStatsByGroup(ctx, since)
+	if err != nil {
+		log.Printf("Ошибка отчёта по теневому режиму: %v", err)
+		h.send(ctx, userID, messages.MsgError)
+		return
+	}
+	byRule, err := h.db.GetShadowStatsByRule(ctx, since)
+	if err != nil {
+		log.Printf("Ошибка отчёта по теневому режиму: %v", err)
+		h.send(ctx, userID, messages.MsgError)
+		return
+	}
+	if len(byGroup) == 0 {
+		h.send(ctx, userID, fmt.Sprintf("👻 За %d дн. теневых срабатываний нет.", days))
+		return
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "👻 Теневой режим за %d дн.\n\nГруппы:", days)
+	for _, st := range byGroup {
+		name := strconv.FormatInt(st.GroupID, 10)
+		if g, err := h.db.GetGroup(ctx, st.GroupID); err == nil {
+			name = g.Title
+		}
+		b.WriteString("\n• " + name + ": " + formatShadowStats(st))
+	}
+	if len(byRule) > 0 {
+		b.WriteString("\n\nПравила:")
+		for _, st := range byRule {
+			name := fmt.Sprintf("#%d", st.RuleID)
+			if r, err := h.db.GetSpamRule(ctx, st.RuleID); err == nil {
+				name += " «" + r.Name + "»"
+			}
+			b.WriteString("\n• " + name + ": " + formatShadowStats(st))
+		}
+	}
+	h.send(ctx, userID, b.String())
+}
+
+func formatShadowStats(st database.ShadowStats) string {
+	line := fmt.Sprintf("%d срабатываний, спам %d, не спам %d, не проверено %d",
+		st.Hits, st.Confirmed, st.Rejected, st.Hits-st.Confirmed-st.Rejected)
+	if reviewed := st.Confirmed + st.Rejected; reviewed > 0 {
+		line += fmt.Sprintf(" — точность %d%%", st.Confirmed*100/reviewed)
+	}
+	return line
+}
+
+// parseTrustArgs разбирает «<user_id> [group_id] [заметка]». Id группы
+// отрицательный, поэтому его легко отличить от начала заметки.
+func parseTrustArgs(args string) (userID int64, groupID *int64, note string, ok bool) {
+	fields := strings.Fields(args)
+	if len(fields) == 0 {
+		return 0, nil, "", false
+	}
+	userID, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil || userID <= 0 {
+		return 0, nil, "", false
+	}
+	fields = fields[1:]
+	if len(fields) > 0 {
+		if id, err := strconv.ParseInt(fields[0], 10, 64); err == nil && id < 0 {
+			groupID = &id
+			fields = fields[1:]
+		}
+	}
+	return userID, groupID, strings.Join(fields, " "), true
+}
+
+func formatTrustScope(groupID *int64) string {
+	if groupID == nil {
+		return "во всех группах"
+	}
+	return fmt.Sprintf("в группе %d", *groupID)
+}
+
+// trustMember добавляет доверенного участника: /trust <user_id> [group_id] [заметка]
+func (h *Handler) trustMember(ctx context.Context, from *models.User, args string) {
+	userID, groupID, note, ok := parseTrustArgs(args)
+	if !ok {
+		h.send(ctx, from.ID, messages.MsgTrustUsage)
+		return
+	}
+
+	err := h.db.AddTrustedMember(ctx, &database.TrustedMember{
+		UserID:  userID,
+		GroupID: groupID,
+		Note:    ptrStr(note),
+		AddedBy: &from.ID,
+	})
+	if err != nil {
+		log.Printf("Ошибка добавления доверенного участника %d: %v", userID, err)
+		h.send(ctx, from.ID, messages.MsgError)
+		return
+	}
+
+	scope := formatTrustScope(groupID)
+	h.send(ctx, from.ID, fmt.Sprintf("✅ Сообщения пользователя %d %s не проверяются на спам.", userID, scope))
+	tglog.Send("🤝 %s (id: %d) добавил доверенного участника %d %s", html.EscapeString(from.FirstName), from.ID, userID, scope)
+}
+
+// untrustMember убирает доверенного участника: /untrust <user_id> [group_id]
+func (h *Handler) untrustMember(ctx context.Context, from *models.User, args string) {
+	userID, groupID, _, ok := parseTrustArgs(args)
+	if !ok {
+		h.send(ctx, from.ID, messages.MsgTrustUsage)
+		return
+	}
+
+	found, err := h.db.RemoveTrustedMember(ctx, userID, groupID)
+	if err != nil {
+		log.Printf("Ошибка удаления доверенного участника %d: %v", userID, err)
+		h.send(ctx, from.ID, messages.MsgError)
+		return
+	}
+
+	scope := formatTrustScope(groupID)
+	if !found {
+		h.send(ctx, from.ID, fmt.Sprintf("ℹ️ Пользователь %d не в списке доверенных %s.", userID, scope))
+		return
+	}
+	h.send(ctx, from.ID, fmt.Sprintf("✅ Пользователь %d убран из доверенных %s.", userID, scope))
+	tglog.Send("🤝 %s (id: %d) убрал доверенного участника %d %s", html.EscapeString(from.FirstName), from.ID, userID, scope)
+}
+
+func (h *Handler) sendTrustedList(ctx context.Context, userID int64) {
+	members, err := h.db.GetTrustedMembers(ctx)
+	if err != nil {
+		log.Printf("Ошибка получения доверенных участников: %v", err)
+		h.send(ctx, userID, messages.MsgError)
+		return
+	}
+	if len(members) == 0 {
+		h.send(ctx, userID, messages.MsgTrustedEmpty)
+		return
+	}
+
+	var b strings.Builder
+	b.WriteString("🤝 Доверенные участники:\n")
+	for _, m := range members {
+		fmt.Fprintf(&b, "\n• %d — %s", m.UserID, formatTrustScope(m.GroupID))
+		if m.Note != nil {
+			b.WriteString(" (" + *m.Note + ")")
+		}
+	}
+	h.send(ctx, userID, b.String())
+}
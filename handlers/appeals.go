@@ -0,0 +1,277 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"log"
+	"strconv"
+	"strings"
+
+	"go_payment_bot/database"
+	"go_payment_bot/messages"
+	"go_payment_bot/moderation"
+	"go_payment_bot/tglog"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+)
+
+// Текст в уведомлении об удалении урезается, чтобы уложиться в лимит сообщения Telegram
+const appealTextLimit = 3000
+
+// Подпись к фото ограничена 1024 символами вместе с заголовком
+const captionTextLimit = 900
+
+// offerAppeal отправляет автору удалённого сообщения его текст и кнопку «Обжаловать».
+// Если пользователь не запускал бота, написать ему нельзя — это не ошибка.
+func (h *Handler) offerAppeal(ctx context.Context, msg *models.Message, violationID int) {
+	text := moderation.MessageText(msg)
+	if violationID == 0 || text == "" {
+		return
+	}
+
+	_, err := h.bot.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:    msg.From.ID,
+		Text:      messages.FormatSpamRemoved(msg.Chat.Title, truncateRunes(text, appealTextLimit)),
+		ParseMode: models.ParseModeHTML,
+		ReplyMarkup: &models.InlineKeyboardMarkup{
+			InlineKeyboard: [][]models.InlineKeyboardButton{{
+				{Text: "📨 Обжаловать", CallbackData: fmt.Sprintf("appeal_%d", violationID)},
+			}},
+		},
+	})
+	if err != nil {
+		log.Printf("Не удалось отправить уведомление об удалении user=%d: %v", msg.From.ID, err)
+	}
+}
+
+// handleAppealCallback — кнопки апелляций.
+// Форматы: appeal_<violation_id> (пользователь),
+// appeal_<restore|whitelist|reject>_<violation_id> (модератор в лог-канале)
+func (h *Handler) handleAppealCallback(ctx context.Context, cb *models.CallbackQuery) {
+	parts := strings.Split(cb.Data, "_")
+	switch len(parts) {
+	case 2:
+		id, err := strconv.Atoi(parts[1])
+		if err == nil {
+			h.submitAppeal(ctx, cb, id)
+		}
+	case 3:
+		id, err := strconv.Atoi(parts[2])
+		if err == nil && h.isAdmin(cb.From.ID) {
+			h.resolveAppeal(ctx, cb, parts[1], id)
+		}
+	}
+}
+
+func (h *Handler) submitAppeal(ctx context.Context, cb *models.CallbackQuery, id int) {
+	ok, err := h.db.CreateAppeal(ctx, id, cb.From.ID)
+	if err != nil {
+		log.Printf("Ошибка сохранения апелляции по нарушению %d: %v", id, err)
+		h.send(ctx, cb.From.ID, messages.MsgError)
+		return
+	}
+	if !ok {
+		h.send(ctx, cb.From.ID, messages.MsgAppealUnavailable)
+		return
+	}
+
+	// Имя нужно для подписи при восстановлении сообщения
+	_, _ = h.db.GetOrCreateUser(ctx, cb.From.ID, ptrStr(cb.From.Username), ptrStr(cb.From.FirstName), ptrStr(cb.From.LastName))
+
+	if msg := cb.Message.Message; msg != nil {
+		_, _ = h.bot.EditMessageReplyMarkup(ctx, &bot.EditMessageReplyMarkupParams{
+			ChatID:    msg.Chat.ID,
+			MessageID: msg.ID,
+		})
+	}
+	h.send(ctx, cb.From.ID, messages.MsgAppealSent)
+
+	v, err := h.db.GetSpamViolation(ctx, id)
+	if err != nil {
+		log.Printf("Ошибка получения нарушения %d: %v", id, err)
+		return
+	}
+
+	buttons := []models.InlineKeyboardButton{
+		{Text: "♻️ Восстановить", CallbackData: fmt.Sprintf("appeal_restore_%d", id)},
+	}
+	if violationDomain(v) != "" {
+		buttons = append(buttons, models.InlineKeyboardButton{
+			Text: "🌐 Домен в белый список", CallbackData: fmt.Sprintf("appeal_whitelist_%d", id),
+		})
+	}
+	buttons = append(buttons, models.InlineKeyboardButton{
+		Text: "🚫 Отклонить", CallbackData: fmt.Sprintf("appeal_reject_%d", id),
+	})
+
+	log.Printf("Апелляция по нарушению %d от user=%d", id, cb.From.ID)
+	tglog.SendWithMarkup(&models.InlineKeyboardMarkup{
+		InlineKeyboard: [][]models.InlineKeyboardButton{buttons},
+	}, "📨 Апелляция #%d: %s (id: %d)\n%s\n\n%s", id, html.EscapeString(cb.From.FirstName), cb.From.ID,
+		html.EscapeString(violationExplain(v)), html.EscapeString(truncateRunes(derefStr(v.MessageText), appealTextLimit)))
+}
+
+func (h *Handler) resolveAppeal(ctx context.Context, cb *models.CallbackQuery, decision string, id int) {
+	var status database.AppealStatus
+	switch decision {
+	case "restore":
+		status = database.AppealRestored
+	case "whitelist":
+		status = database.AppealWhitelisted
+	case "reject":
+		status = database.AppealRejected
+	default:
+		return
+	}
+
+	v, err := h.db.GetSpamViolation(ctx, id)
+	if err != nil {
+		log.Printf("Ошибка получения нарушения %d: %v", id, err)
+		return
+	}
+
+	if status == database.AppealWhitelisted {
+		domain := violationDomain(v)
+		if domain == "" {
+			return
+		}
+		if err := h.db.AddAllowedDomain(ctx, domain, fmt.Sprintf("апелляция #%d", id)); err != nil {
+			log.Printf("Ошибка добавления домена %s: %v", domain, err)
+			return
+		}
+		h.LoadAllowedDomains(ctx)
+		h.notifyReload(ctx)
+		tglog.Send("🌐 %s (id: %d) добавил %s в белый список по апелляции #%d",
+			html.EscapeString(cb.From.FirstName), cb.From.ID, html.EscapeString(domain), id)
+	}
+
+	// Решение сохраняется до восстановления, чтобы двойное нажатие не продублировало сообщение
+	ok, err := h.db.ResolveAppeal(ctx, id, status, cb.From.ID)
+	if err != nil {
+		log.Printf("Ошибка сохранения решения по апелляции %d: %v", id, err)
+		return
+	}
+	if !ok {
+		return
+	}
+
+	// Решение по апелляции — разметка для классификатора
+	if status == database.AppealRejected {
+		h.learnBayes(ctx, id, database.ModVerdictSpam)
+	} else {
+		h.learnBayes(ctx, id, database.ModVerdictHam)
+	}
+
+	result := "🚫 Отклонено"
+	if status == database.AppealRejected {
+		h.send(ctx, v.UserID, messages.MsgAppealRejected)
+	} else {
+		result = "♻️ Восстановлено"
+		if status == database.AppealWhitelisted {
+			result = "🌐 Домен в белом списке, восстановлено"
+		}
+		if err := h.restoreMessage(ctx, v, messages.FormatRestoredMessage); err != nil {
+			log.Printf("Ошибка восстановления сообщения по апелляции %d: %v", id, err)
+			result += " (не удалось отправить в группу)"
+		} else {
+			h.send(ctx, v.UserID, messages.MsgAppealRestored)
+		}
+	}
+
+	log.Printf("Апелляция %d: %s, модератор %d", id, status, cb.From.ID)
+	if msg := cb.Message.Message; msg != nil {
+		h.markResolved(ctx, msg, result, cb.From.FirstName)
+	}
+}
+
+// markResolved дописывает решение модератора к сообщению в лог-канале и убирает
+// кнопки. Текст только дополняется в конце, поэтому entities исходного
+// сообщения (ссылки, жирный шрифт) остаются на своих местах.
+func (h *Handler) markResolved(ctx context.Context, msg *models.Message, result, moderator string) {
+	_, err := h.bot.EditMessageText(ctx, &bot.EditMessageTextParams{
+		ChatID:    msg.Chat.ID,
+		MessageID: msg.ID,
+		Text:      fmt.Sprintf("%s\n\n%s — %s", msg.Text, result, moderator),
+		Entities:  msg.Entities,
+	})
+	if err != nil {
+		log.Printf("Ошибка обновления сообщения в лог-канале: %v", err)
+	}
+}
+
+// restoreMessage публикует удалённый текст от имени бота с указанием автора.
+// Сохранённое фото публикуется вместе с текстом в подписи.
+func (h *Handler) restoreMessage(ctx context.Context, v *database.SpamViolation, format func(int64, string, string) string) error {
+	name := strconv.FormatInt(v.UserID, 10)
+	if u, err := h.db.GetUser(ctx, v.UserID); err == nil && u.FirstName != nil {
+		name = *u.FirstName
+	}
+
+	if v.PhotoFileID != nil {
+		params := &bot.SendPhotoParams{
+			ChatID:    v.GroupID,
+			Photo:     &models.InputFileString{Data: *v.PhotoFileID},
+			Caption:   format(v.UserID, name, truncateRunes(derefStr(v.MessageText), captionTextLimit)),
+			ParseMode: models.ParseModeHTML,
+		}
+		if v.TopicID != nil {
+			params.MessageThreadID = *v.TopicID
+		}
+		_, err := h.bot.SendPhoto(ctx, params)
+		return err
+	}
+
+	params := &bot.SendMessageParams{
+		ChatID:    v.GroupID,
+		Text:      format(v.UserID, name, truncateRunes(derefStr(v.MessageText), appealTextLimit)),
+		ParseMode: models.ParseModeHTML,
+	}
+	if v.TopicID != nil {
+		params.MessageThreadID = *v.TopicID
+	}
+	_, err := h.bot.SendMessage(ctx, params)
+	return err
+}
+
+// violationDomain — домен ссылки, из-за которой удалено сообщение, или пустая строка
+func violationDomain(v *database.SpamViolation) string {
+	var signals []moderation.Signal
+	if err := json.Unmarshal(v.Signals, &signals); err != nil {
+		return ""
+	}
+	for _, s := range signals {
+		if s.Type == moderation.SignalLink {
+			if host := moderation.LinkHost(s.Match); host != "" {
+				return host
+			}
+		}
+	}
+	return ""
+}
+
+// violationExplain восстанавливает объяснение оценки из сохранённых сигналов
+func violationExplain(v *database.SpamViolation) string {
+	verdict := moderation.Verdict{Score: v.Score}
+	if err := json.Unmarshal(v.Signals, &verdict.Signals); err != nil || len(verdict.Signals) == 0 {
+		return fmt.Sprintf("%s: %s = %d", v.ViolationType, derefStr(v.MatchFound), v.Score)
+	}
+	return verdict.Explain()
+}
+
+func truncateRunes(s string, limit int) string {
+	r := []rune(s)
+	if len(r) <= limit {
+		return s
+	}
+	return string(r[:limit]) + "…"
+}
+
+func derefStr(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
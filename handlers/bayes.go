@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"context"
+	"log"
+
+	"go_payment_bot/database"
+	"go_payment_bot/moderation"
+)
+
+// LoadBayes загружает модель классификатора из БД
+func (h *Handler) LoadBayes(ctx context.Context) {
+	tokens, err := h.db.GetBayesTokens(ctx)
+	if err != nil {
+		log.Printf("Ошибка загрузки модели классификатора: %v", err)
+		return
+	}
+	counts := make(map[string]moderation.BayesCounts, len(tokens))
+	for _, t := range tokens {
+		counts[t.Token] = moderation.BayesCounts{Spam: t.SpamCount, Ham: t.HamCount}
+	}
+	h.bayes.Load(counts)
+
+	docs, ok := counts[""]
+	words := len(counts)
+	if ok {
+		words--
+	}
+	log.Printf("Загружена модель классификатора: %d токенов, %d спам и %d не спам сообщений",
+		words, docs.Spam, docs.Ham)
+}
+
+// bayesSignals — сигнал классификатора для текста сообщения
+func (h *Handler) bayesSignals(text string) []moderation.Signal {
+	if s, ok := h.bayes.Signal(text); ok {
+		return []moderation.Signal{s}
+	}
+	return nil
+}
+
+// learnBayes дообучает классификатор на нарушении, по которому модератор
+// принял решение: label — spam (подтвердил) или ham (отменил)
+func (h *Handler) learnBayes(ctx context.Context, violationID int, label string) {
+	v, err := h.db.GetSpamViolation(ctx, violationID)
+	if err != nil {
+		log.Printf("Ошибка получения нарушения %d для классификатора: %v", violationID, err)
+		return
+	}
+	text := derefStr(v.MessageText)
+	if text == "" {
+		return
+	}
+
+	tokens := moderation.Tokenize(text)
+	prev, changed, err := h.db.LearnBayes(ctx, violationID, tokens, label)
+	if err != nil {
+		log.Printf("Ошибка дообучения классификатора на нарушении %d: %v", violationID, err)
+		return
+	}
+	if !changed {
+		return
+	}
+	if prev != "" {
+		h.bayes.Learn(tokens, prev == database.ModVerdictSpam, -1)
+	}
+	h.bayes.Learn(tokens, label == database.ModVerdictSpam, 1)
+	log.Printf("Классификатор дообучен на нарушении %d: %s", violationID, label)
+}
@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"time"
+
+	"go_payment_bot/database"
+	"go_payment_bot/jobs"
+	"go_payment_bot/messages"
+	"go_payment_bot/tglog"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+)
+
+// Сколько вариантов ответа показывать в капче
+const captchaOptions = 4
+
+// OnChatMember ловит вход участников в группу (апдейты chat_member)
+// и, если в группе включена капча, ограничивает новичка до ответа
+func (h *Handler) OnChatMember(ctx context.Context, b *bot.Bot, update *models.Update) {
+	upd := update.ChatMember
+	if upd == nil || upd.Chat.Type != "supergroup" {
+		return
+	}
+	if isChatMember(upd.OldChatMember) || !isChatMember(upd.NewChatMember) {
+		return
+	}
+	user := chatMemberUser(upd.NewChatMember)
+	if user == nil || user.IsBot {
+		return
+	}
+
+	// Добавленных администратором не проверяем
+	if upd.From.ID != user.ID && h.isChatAdmin(ctx, upd.Chat.ID, upd.From.ID) {
+		return
+	}
+
+	group := h.groupPolicies(ctx, upd.Chat.ID)
+	if !group.CaptchaEnabled {
+		return
+	}
+	verified, err := h.db.IsMemberVerified(ctx, user.ID, upd.Chat.ID)
+	if err != nil {
+		log.Printf("Ошибка проверки капчи user=%d: %v", user.ID, err)
+		return
+	}
+	if verified {
+		return
+	}
+
+	h.startCaptcha(ctx, &upd.Chat, user, h.captchaTimeout(group))
+}
+
+func (h *Handler) captchaTimeout(group *database.Group) time.Duration {
+	if group.CaptchaTimeout != nil {
+		return time.Duration(*group.CaptchaTimeout) * time.Second
+	}
+	return h.cfg.CaptchaTimeout
+}
+
+func (h *Handler) startCaptcha(ctx context.Context, chat *models.Chat, user *models.User, timeout time.Duration) {
+	if err := h.restrictUser(ctx, chat.ID, user.ID, 0); err != nil {
+		log.Printf("Ошибка ограничения нового участника user=%d: %v", user.ID, err)
+		return
+	}
+
+	a, b := rand.IntN(9)+1, rand.IntN(9)+1
+	answer := a + b
+	expiresAt := time.Now().Add(timeout)
+
+	v, err := h.db.StartMemberVerification(ctx, user.ID, chat.ID, answer, expiresAt)
+	if err != nil {
+		log.Printf("Ошибка сохранения капчи user=%d: %v", user.ID, err)
+		return
+	}
+
+	var row []models.InlineKeyboardButton
+	for _, option := range captchaAnswers(answer) {
+		row = append(row, models.InlineKeyboardButton{
+			Text:         strconv.Itoa(option),
+			CallbackData: fmt.Sprintf("captcha_%d_%d", v.ID, option),
+		})
+	}
+
+	msg, err := h.bot.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:      chat.ID,
+		Text:        messages.FormatCaptcha(user.ID, user.FirstName, a, b, int(timeout.Seconds())),
+		ParseMode:   models.ParseModeHTML,
+		ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: [][]models.InlineKeyboardButton{row}},
+	})
+	if err != nil {
+		log.Printf("Ошибка отправки капчи user=%d: %v", user.ID, err)
+	} else if err := h.db.SetMemberVerificationMessage(ctx, v.ID, msg.ID); err != nil {
+		log.Printf("Ошибка сохранения сообщения капчи %d: %v", v.ID, err)
+	}
+
+	// Ключ задачи по проверке: при повторном входе таймаут переносится
+	payload := jobs.CaptchaTimeout{VerificationID: v.ID}
+	if err := h.queue.Enqueue(ctx, jobs.TypeCaptchaTimeout, fmt.Sprintf("captcha_%d", v.ID), payload, expiresAt); err != nil {
+		log.Printf("Ошибка постановки таймаута капчи %d: %v", v.ID, err)
+	}
+
+	log.Printf("Капча для user=%d в chat=%d, ответ за %s", user.ID, chat.ID, timeout)
+}
+
+// captchaAnswers — правильный ответ и неверные варианты в случайном порядке
+func captchaAnswers(answer int) []int {
+	options := []int{answer}
+	for len(options) < captchaOptions {
+		option := rand.IntN(17) + 2 // суммы двух цифр 1..9
+		dup := false
+		for _, o := range options {
+			dup = dup || o == option
+		}
+		if !dup {
+			options = append(options, option)
+		}
+	}
+	rand.Shuffle(len(options), func(i, j int) { options[i], options[j] = options[j], options[i] })
+	return options
+}
+
+// handleCaptchaCallback — ответ на капчу. Формат: captcha_<verification_id>_<ответ>
+func (h *Handler) handleCaptchaCallback(ctx context.Context, cb *models.CallbackQuery) {
+	parts := strings.Split(cb.Data, "_")
+	if len(parts) != 3 {
+		return
+	}
+	id, err1 := strconv.Atoi(parts[1])
+	answer, err2 := strconv.Atoi(parts[2])
+	if err1 != nil || err2 != nil {
+		return
+	}
+
+	v, err := h.db.GetMemberVerification(ctx, id)
+	if err != nil {
+		log.Printf("Ошибка получения капчи %d: %v", id, err)
+		return
+	}
+	// Отвечать может только сам новичок
+	if v.UserID != cb.From.ID || v.Status != database.VerificationPending {
+		return
+	}
+
+	if answer != v.Answer {
+		h.failCaptcha(ctx, v, "неверный ответ")
+		return
+	}
+
+	ok, err := h.db.ResolveMemberVerification(ctx, v.ID, database.VerificationPassed)
+	if err != nil || !ok {
+		return
+	}
+	_, err = h.bot.RestrictChatMember(ctx, &bot.RestrictChatMemberParams{
+		ChatID:      v.GroupID,
+		UserID:      v.UserID,
+		Permissions: h.defaultPermissions(ctx, v.GroupID),
+	})
+	if err != nil {
+		log.Printf("Ошибка снятия ограничений user=%d после капчи: %v", v.UserID, err)
+	}
+	h.deleteCaptchaMessage(ctx, v)
+	log.Printf("Капча пройдена: user=%d в chat=%d", v.UserID, v.GroupID)
+}
+
+// captchaTimeoutJob удаляет участника, не ответившего вовремя
+func (h *Handler) captchaTimeoutJob(ctx context.Context, job *database.Job, payload jobs.CaptchaTimeout) error {
+	v, err := h.db.GetMemberVerification(ctx, payload.VerificationID)
+	if isNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if v.Status != database.VerificationPending || time.Now().Before(v.ExpiresAt) {
+		return nil
+	}
+	h.failCaptcha(ctx, v, "время вышло")
+	return nil
+}
+
+// failCaptcha удаляет участника из группы (бан и сразу разбан — он может
+// войти снова и пройти капчу заново)
+func (h *Handler) failCaptcha(ctx context.Context, v *database.MemberVerification, reason string) {
+	ok, err := h.db.ResolveMemberVerification(ctx, v.ID, database.VerificationFailed)
+	if err != nil {
+		log.Printf("Ошибка сохранения капчи %d: %v", v.ID, err)
+		return
+	}
+	if !ok {
+		return
+	}
+
+	_, err = h.bot.BanChatMember(ctx, &bot.BanChatMemberParams{ChatID: v.GroupID, UserID: v.UserID})
+	if err != nil {
+		log.Printf("Ошибка удаления user=%d, не прошедшего капчу: %v", v.UserID, err)
+	} else {
+		_, _ = h.bot.UnbanChatMember(ctx, &bot.UnbanChatMemberParams{ChatID: v.GroupID, UserID: v.UserID, OnlyIfBanned: true})
+	}
+	h.deleteCaptchaMessage(ctx, v)
+
+	log.Printf("Капча не пройдена (%s): user=%d удалён из chat=%d", reason, v.UserID, v.GroupID)
+	tglog.Send("🚪 %d удалён из группы %d: капча не пройдена (%s)", v.UserID, v.GroupID, reason)
+}
+
+func (h *Handler) deleteCaptchaMessage(ctx context.Context, v *database.MemberVerification) {
+	if v.MessageID == nil {
+		return
+	}
+	_, err := h.bot.DeleteMessage(ctx, &bot.DeleteMessageParams{ChatID: v.GroupID, MessageID: *v.MessageID})
+	if err != nil {
+		log.Printf("Ошибка удаления сообщения капчи %d: %v", v.ID, err)
+	}
+}
+
+// isChatMember — пользователь состоит в группе (в том числе с ограничениями)
+func isChatMember(m models.ChatMember) bool {
+	switch m.Type {
+	case models.ChatMemberTypeOwner, models.ChatMemberTypeAdministrator, models.ChatMemberTypeMember:
+		return true
+	case models.ChatMemberTypeRestricted:
+		return m.Restricted != nil && m.Restricted.IsMember
+	}
+	return false
+}
+
+func chatMemberUser(m models.ChatMember) *models.User {
+	switch {
+	case m.Owner != nil:
+		return m.Owner.User
+	case m.Administrator != nil:
+		return &m.Administrator.User
+	case m.Member != nil:
+		return m.Member.User
+	case m.Restricted != nil:
+		return m.Restricted.User
+	case m.Left != nil:
+		return m.Left.User
+	case m.Banned != nil:
+		return m.Banned.User
+	}
+	return nil
+}
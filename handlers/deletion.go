@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"strings"
+	"time"
+
+	"go_payment_bot/database"
+	"go_payment_bot/tglog"
+
+	"github.com/go-telegram/bot"
+)
+
+// maxDeleteBatch — лимит Bot API на число id в одном deleteMessages
+const maxDeleteBatch = 100
+
+// chatMessage — сообщение в конкретном чате
+type chatMessage struct {
+	ChatID    int64
+	MessageID int
+}
+
+// deleteMessagesBulk удаляет сообщения через deleteMessages пачками до 100 id
+// на чат. Если пачка не удалилась, её сообщения удаляются по одному.
+// Возвращает ошибки только по сообщениям, которые удалить не удалось.
+func (h *Handler) deleteMessagesBulk(ctx context.Context, byChat map[int64][]int) map[chatMessage]error {
+	failed := make(map[chatMessage]error)
+
+	for chatID, ids := range byChat {
+		for start := 0; start < len(ids); start += maxDeleteBatch {
+			batch := ids[start:min(start+maxDeleteBatch, len(ids))]
+
+			_, err := h.bot.DeleteMessages(ctx, &bot.DeleteMessagesParams{
+				ChatID:     chatID,
+				MessageIDs: batch,
+			})
+			if err == nil {
+				continue
+			}
+
+			// При флуд-контроле поштучное удаление только усугубит ситуацию
+			var tooMany *bot.TooManyRequestsError
+			if errors.As(err, &tooMany) || len(batch) == 1 {
+				for _, id := range batch {
+					failed[chatMessage{chatID, id}] = err
+				}
+				continue
+			}
+
+			log.Printf("Пакетное удаление в chat=%d не удалось (%v), удаляем по одному", chatID, err)
+			for _, id := range batch {
+				_, err := h.bot.DeleteMessage(ctx, &bot.DeleteMessageParams{
+					ChatID:    chatID,
+					MessageID: id,
+				})
+				if err != nil {
+					failed[chatMessage{chatID, id}] = err
+				}
+			}
+		}
+	}
+
+	return failed
+}
+
+// classifyDeleteError относит ошибку deleteMessage к одному из классов:
+// сообщения уже нет (gone), у бота нет прав (no_rights) или стоит повторить позже (pending).
+// Для "повторить позже" возвращает рекомендованную Telegram задержку, если она есть.
+func classifyDeleteError(err error) (database.MessageDeletionStatus, time.Duration) {
+	if err == nil {
+		return database.MessageDeletionDeleted, 0
+	}
+
+	var tooMany *bot.TooManyRequestsError
+	if errors.As(err, &tooMany) {
+		return database.MessageDeletionPending, time.Duration(tooMany.RetryAfter) * time.Second
+	}
+
+	if errors.Is(err, bot.ErrorForbidden) {
+		// Бота удалили из группы или заблокировали
+		return database.MessageDeletionNoRights, 0
+	}
+
+	if errors.Is(err, bot.ErrorBadRequest) {
+		desc := strings.ToLower(err.Error())
+		switch {
+		case strings.Contains(desc, "message to delete not found"),
+			strings.Contains(desc, "message_id_invalid"):
+			return database.MessageDeletionGone, 0
+		case strings.Contains(desc, "can't be deleted"),
+			strings.Contains(desc, "not enough rights"),
+			strings.Contains(desc, "have no rights"),
+			strings.Contains(desc, "chat not found"):
+			return database.MessageDeletionNoRights, 0
+		}
+	}
+
+	// Сеть, 5xx и прочее — временные ошибки
+	return database.MessageDeletionPending, 0
+}
+
+// alertPostDeletionFailed сообщает в лог-канал о посте, который не удалось удалить
+func alertPostDeletionFailed(p *database.ExpiredPost, topicTitle string, deletions []database.PostMessageDeletion) {
+	var lines []string
+	for _, d := range deletions {
+		if d.IsFinal() {
+			continue
+		}
+		reason := "—"
+		if d.LastError != nil {
+			reason = *d.LastError
+		}
+		lines = append(lines, fmt.Sprintf("• %d: %s (%s)", d.MessageID, d.Status, html.EscapeString(reason)))
+	}
+
+	tglog.Send("🚨 Не удалось удалить просроченный пост %d после %d попыток\nТема «%s», chat %d, user %d\n%s\n\nУдалите сообщения вручную.",
+		p.ID, p.DeletionAttempts, html.EscapeString(topicTitle), p.ChatID, p.UserID, strings.Join(lines, "\n"))
+}
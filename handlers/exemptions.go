@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+)
+
+// Как долго список администраторов группы считается актуальным
+const chatAdminsTTL = 10 * time.Minute
+
+// exemption — насколько проверка на спам ослаблена для отправителя
+type exemption int
+
+const (
+	exemptNone exemption = iota
+	// Сообщение проверяется, но не удаляется — самое строгое действие предупреждение
+	exemptSoft
+	// Сообщение не проверяется
+	exemptFull
+)
+
+// chatAdmins — кэш администраторов групп из getChatAdministrators
+// и привязанных к группам каналов
+type chatAdmins struct {
+	mu     sync.Mutex
+	groups map[int64]chatAdminsEntry
+}
+
+type chatAdminsEntry struct {
+	ids          map[int64]bool
+	linkedChatID int64 // канал, к которому группа привязана как обсуждение
+	fetchedAt    time.Time
+}
+
+// exemption — исключение для автора сообщения
+func (h *Handler) exemption(ctx context.Context, msg *models.Message) (exemption, string) {
+	return h.userExemption(ctx, msg.Chat.ID, msg.From.ID)
+}
+
+// userExemption определяет, проверять ли сообщения участника: администраторы
+// группы и доверенные участники не проверяются, у клиентов с активным платным
+// постом сообщение не удаляется. Возвращает уровень и причину для лога.
+func (h *Handler) userExemption(ctx context.Context, chatID, userID int64) (exemption, string) {
+	if h.isChatAdmin(ctx, chatID, userID) {
+		return exemptFull, "администратор группы"
+	}
+
+	trusted, err := h.db.IsTrustedMember(ctx, userID, chatID)
+	if err != nil {
+		log.Printf("Ошибка проверки доверенного участника user=%d: %v", userID, err)
+	} else if trusted {
+		return exemptFull, "доверенный участник"
+	}
+
+	paying, err := h.db.HasActivePost(ctx, userID, chatID)
+	if err != nil {
+		log.Printf("Ошибка проверки активных постов user=%d: %v", userID, err)
+	} else if paying {
+		return exemptSoft, "активное платное размещение"
+	}
+
+	return exemptNone, ""
+}
+
+// isChatAdmin проверяет по кэшу, администратор ли пользователь в группе
+func (h *Handler) isChatAdmin(ctx context.Context, chatID, userID int64) bool {
+	return h.chatAdminsEntry(ctx, chatID).ids[userID]
+}
+
+// linkedChatID — id привязанного к группе канала или 0
+func (h *Handler) linkedChatID(ctx context.Context, chatID int64) int64 {
+	return h.chatAdminsEntry(ctx, chatID).linkedChatID
+}
+
+// chatAdminsEntry возвращает данные группы из кэша, обновляя устаревшие.
+// При ошибке запроса используются прежние данные, если они есть.
+func (h *Handler) chatAdminsEntry(ctx context.Context, chatID int64) chatAdminsEntry {
+	h.admins.mu.Lock()
+	entry, ok := h.admins.groups[chatID]
+	h.admins.mu.Unlock()
+
+	if ok && time.Since(entry.fetchedAt) <= chatAdminsTTL {
+		return entry
+	}
+
+	members, err := h.bot.GetChatAdministrators(ctx, &bot.GetChatAdministratorsParams{ChatID: chatID})
+	if err != nil {
+		log.Printf("Ошибка получения администраторов chat=%d: %v", chatID, err)
+		return entry
+	}
+
+	fresh := chatAdminsEntry{ids: make(map[int64]bool, len(members)), fetchedAt: time.Now()}
+	for _, m := range members {
+		switch {
+		case m.Owner != nil && m.Owner.User != nil:
+			fresh.ids[m.Owner.User.ID] = true
+		case m.Administrator != nil:
+			fresh.ids[m.Administrator.User.ID] = true
+		}
+	}
+
+	if chat, err := h.bot.GetChat(ctx, &bot.GetChatParams{ChatID: chatID}); err == nil {
+		fresh.linkedChatID = chat.LinkedChatID
+	} else {
+		log.Printf("Ошибка получения группы chat=%d: %v", chatID, err)
+		fresh.linkedChatID = entry.linkedChatID
+	}
+
+	h.admins.mu.Lock()
+	h.admins.groups[chatID] = fresh
+	h.admins.mu.Unlock()
+	return fresh
+}
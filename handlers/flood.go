@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"log"
+	"time"
+
+	"go_payment_bot/database"
+	"go_payment_bot/messages"
+	"go_payment_bot/moderation"
+	"go_payment_bot/tglog"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+)
+
+// checkFlood учитывает сообщение в детекторе флуда и рейдов и применяет
+// действие группы. Возвращает true, если сообщение удалено.
+func (h *Handler) checkFlood(ctx context.Context, msg *models.Message) bool {
+	res := h.flood.Add(moderation.FloodMessage{
+		ChatID:    msg.Chat.ID,
+		UserID:    msg.From.ID,
+		MessageID: msg.ID,
+		Text:      moderation.MessageText(msg),
+	})
+
+	switch res.Kind {
+	case moderation.FloodNone:
+		return false
+	case moderation.FloodGroup:
+		// Пишут много разных людей — удалять нечего, только замедляем
+		if res.First {
+			h.enableSlowMode(ctx, msg, fmt.Sprintf("%d сообщений за %s", res.Count, h.cfg.FloodWindow))
+		}
+		return false
+	}
+
+	group := h.groupPolicies(ctx, msg.Chat.ID)
+	action, what := group.FloodAction, fmt.Sprintf("флуд: %d сообщений за %s", res.Count, h.cfg.FloodWindow)
+	if res.Kind == moderation.FloodRaid {
+		action, what = group.RaidAction, fmt.Sprintf("рейд: одинаковый текст от %d пользователей за %s", res.Count, h.cfg.RaidWindow)
+	}
+
+	// Администраторов и доверенных участников флуд не касается
+	exempt := make(map[int64]bool)
+	var users []int64
+	for _, userID := range res.Users {
+		if level, reason := h.userExemption(ctx, msg.Chat.ID, userID); level == exemptFull {
+			log.Printf("Флуд-контроль пропустил user=%d в chat=%d (%s)", userID, msg.Chat.ID, reason)
+			exempt[userID] = true
+			continue
+		}
+		users = append(users, userID)
+	}
+	if len(users) == 0 {
+		return false
+	}
+
+	if action == database.FloodSlowMode {
+		if res.First {
+			h.enableSlowMode(ctx, msg, what)
+		}
+		return false
+	}
+
+	deleted := false
+	var ids []int
+	for _, m := range res.Messages {
+		if exempt[m.UserID] {
+			continue
+		}
+		ids = append(ids, m.MessageID)
+		deleted = deleted || m.MessageID == msg.ID
+	}
+	if failed := h.deleteMessagesBulk(ctx, map[int64][]int{msg.Chat.ID: ids}); len(failed) > 0 {
+		log.Printf("Не удалено %d из %d сообщений флуда в chat=%d", len(failed), len(ids), msg.Chat.ID)
+	}
+
+	muted := 0
+	for _, userID := range users {
+		h.saveFloodViolation(ctx, msg, userID, res)
+		if action == database.FloodMute && h.muteFlooder(ctx, msg.Chat.ID, userID, what) {
+			muted++
+		}
+	}
+
+	log.Printf("%s в chat=%d: удалено %d сообщений, замьючено %d из %d пользователей", what, msg.Chat.ID, len(ids), muted, len(users))
+	if res.First || muted > 0 {
+		tglog.Send("🌊 %s в группе %s\nДействие: %s, удалено сообщений: %d, замьючено: %d\n\n%s",
+			what, html.EscapeString(msg.Chat.Title), action, len(ids), muted,
+			html.EscapeString(truncateRunes(moderation.MessageText(msg), 500)))
+	}
+	return deleted
+}
+
+// muteFlooder мьютит автора флуда на FloodMuteDuration и записывает санкцию
+func (h *Handler) muteFlooder(ctx context.Context, chatID, userID int64, reason string) bool {
+	until := time.Now().Add(h.cfg.FloodMuteDuration)
+	if err := h.restrictUser(ctx, chatID, userID, int(until.Unix())); err != nil {
+		log.Printf("Ошибка мьюта за флуд user=%d: %v", userID, err)
+		return false
+	}
+
+	_, err := h.db.CreateSanction(ctx, &database.Sanction{
+		UserID:  userID,
+		GroupID: chatID,
+		Action:  database.SanctionMute,
+		Until:   &until,
+		Reason:  &reason,
+	})
+	if err != nil {
+		log.Printf("Ошибка сохранения санкции user=%d: %v", userID, err)
+	}
+	return true
+}
+
+func (h *Handler) saveFloodViolation(ctx context.Context, msg *models.Message, userID int64, res moderation.FloodResult) {
+	var text string
+	for _, m := range res.Messages {
+		if m.UserID == userID {
+			text = m.Text
+			break
+		}
+	}
+
+	v := &database.SpamViolation{
+		UserID:        userID,
+		GroupID:       msg.Chat.ID,
+		MessageText:   &text,
+		ViolationType: string(res.Kind),
+		MatchFound:    ptrStr(fmt.Sprintf("%d", res.Count)),
+		Action:        string(moderation.ActionDelete),
+	}
+	if msg.MessageThreadID != 0 {
+		v.TopicID = &msg.MessageThreadID
+	}
+	if _, err := h.db.CreateSpamViolation(ctx, v); err != nil {
+		log.Printf("Ошибка сохранения нарушения: %v", err)
+	}
+}
+
+// enableSlowMode включает (или продлевает) медленный режим в группе и
+// сообщает об этом участникам и в лог-канал
+func (h *Handler) enableSlowMode(ctx context.Context, msg *models.Message, reason string) {
+	now := time.Now()
+	until := now.Add(h.cfg.SlowModeDuration)
+	h.slowModes.Enable(msg.Chat.ID, now, until)
+
+	untilText := messages.FormatScheduleTime(until.In(h.groupLocation(ctx, msg.Chat.ID)))
+	log.Printf("Медленный режим в chat=%d до %s: %s", msg.Chat.ID, untilText, reason)
+	tglog.Send("🐢 Медленный режим в группе %s до %s — %s", html.EscapeString(msg.Chat.Title), untilText, reason)
+
+	notice, err := h.bot.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:          msg.Chat.ID,
+		MessageThreadID: msg.MessageThreadID,
+		Text:            messages.FormatSlowMode(h.cfg.SlowModeDelay, untilText),
+	})
+	if err != nil {
+		log.Printf("Ошибка отправки уведомления о медленном режиме: %v", err)
+		return
+	}
+	h.deleteMessageLater(ctx, msg.Chat.ID, notice.ID, h.cfg.SlowModeDuration)
+}
+
+// enforceSlowMode удаляет сообщение, если в группе медленный режим,
+// а пользователь писал недавно. Возвращает true, если сообщение удалено.
+func (h *Handler) enforceSlowMode(ctx context.Context, msg *models.Message) bool {
+	if !h.slowModes.TooSoon(msg.Chat.ID, msg.From.ID, time.Now(), h.cfg.SlowModeDelay) {
+		return false
+	}
+	if level, _ := h.exemption(ctx, msg); level == exemptFull {
+		return false
+	}
+
+	h.deleteSpamMessage(ctx, msg)
+	log.Printf("Медленный режим: удалено сообщение user=%d в chat=%d", msg.From.ID, msg.Chat.ID)
+	return true
+}
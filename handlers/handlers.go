@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"html"
 	"log"
 	"sort"
 	"strconv"
@@ -13,6 +14,7 @@ import (
 
 	"go_payment_bot/config"
 	"go_payment_bot/database"
+	"go_payment_bot/jobs"
 	"go_payment_bot/messages"
 	"go_payment_bot/moderation"
 	"go_payment_bot/tglog"
@@ -49,22 +51,45 @@ type Handler struct {
 	bot             *bot.Bot
 	cfg             *config.Config
 	db              *database.DB
+	queue           *jobs.Queue
 	botUsername     string
-	allowedDomains  []string
+	allowlist       moderation.Allowlist // читать через currentAllowlist
+	rules           moderation.RuleSet   // читать через currentRules
+	moderationMu    sync.RWMutex         // белый список и правила перезагружаются на лету
+	bayes           *moderation.Bayes
 	mediaGroupCache map[string]*MediaGroupData // MediaGroupID -> данные группы
 	mediaGroupMu    sync.Mutex
 	pendingContent  map[int64]*PendingContent // UserID -> контент для предпросмотра
 	pendingMu       sync.Mutex
+	recentTexts     map[recentText]time.Time // недавние сообщения для сигнала repeated_text
+	recentMu        sync.Mutex
+	admins          chatAdmins // кэш администраторов групп
+	flood           *moderation.FloodDetector
+	slowModes       *moderation.SlowModes // медленный режим, включённый ботом
+	qr              qrCache               // распознанные QR-коды по FileUniqueID
 }
 
-func New(b *bot.Bot, cfg *config.Config, db *database.DB, username string) *Handler {
+func New(b *bot.Bot, cfg *config.Config, db *database.DB, queue *jobs.Queue, username string) *Handler {
 	return &Handler{
 		bot:             b,
 		cfg:             cfg,
 		db:              db,
+		queue:           queue,
 		botUsername:     username,
 		mediaGroupCache: make(map[string]*MediaGroupData),
 		pendingContent:  make(map[int64]*PendingContent),
+		recentTexts:     make(map[recentText]time.Time),
+		bayes:           moderation.NewBayes(),
+		admins:          chatAdmins{groups: make(map[int64]chatAdminsEntry)},
+		flood: moderation.NewFloodDetector(moderation.FloodLimits{
+			UserMessages:  cfg.FloodUserLimit,
+			GroupMessages: cfg.FloodGroupLimit,
+			Window:        cfg.FloodWindow,
+			RaidUsers:     cfg.RaidUsers,
+			RaidWindow:    cfg.RaidWindow,
+		}),
+		slowModes: moderation.NewSlowModes(),
+		qr:        qrCache{entries: make(map[string]qrEntry)},
 	}
 }
 
@@ -79,12 +104,20 @@ func (h *Handler) OnMessage(ctx context.Context, b *bot.Bot, update *models.Upda
 		return
 	}
 
+	// Служебные сообщения группы (вход участников, закрепы, темы) не проверяем
+	if msg.Chat.Type == "supergroup" && isServiceMessage(msg) {
+		return
+	}
+
 	// Проверяем, это сообщение в отслеживаемой теме (платные объявления)?
 	// Если да — пропускаем спам-модерацию, там своя логика (удаление + кнопка оплаты)
 	if msg.Chat.Type == "supergroup" && msg.MessageThreadID != 0 {
 		topic, err := h.db.GetTopicByGroupAndTopicID(ctx, msg.Chat.ID, msg.MessageThreadID)
 		if err == nil && topic.IsActive {
-			if msg.From != nil && !msg.From.IsBot {
+			switch {
+			case msg.SenderChat != nil:
+				h.onPaidTopicSender(ctx, msg)
+			case msg.From != nil && !msg.From.IsBot:
 				h.onServicesTopicMessage(ctx, msg, topic)
 			}
 			return
@@ -92,18 +125,9 @@ func (h *Handler) OnMessage(ctx context.Context, b *bot.Bot, update *models.Upda
 	}
 
 	// === МОДЕРАЦИЯ СПАМА В ОСТАЛЬНЫХ ТОПИКАХ ===
-	if msg.Chat.Type == "supergroup" && msg.From != nil && !msg.From.IsBot {
-		text := msg.Text
-		if msg.Caption != "" {
-			text = msg.Caption
-		}
-
-		if text != "" {
-			if violation := moderation.Check(text, h.allowedDomains); violation != nil {
-				h.handleSpamViolation(ctx, msg, violation)
-				return
-			}
-		}
+	if msg.Chat.Type == "supergroup" {
+		h.onGroupMessage(ctx, msg)
+		return
 	}
 
 	if msg.Chat.Type == "private" {
@@ -111,6 +135,32 @@ func (h *Handler) OnMessage(ctx context.Context, b *bot.Bot, update *models.Upda
 	}
 }
 
+// OnEditedMessage пропускает отредактированные сообщения через ту же модерацию:
+// иначе спамер публикует чистый текст, а потом дописывает в него телефон
+func (h *Handler) OnEditedMessage(ctx context.Context, b *bot.Bot, update *models.Update) {
+	msg := update.EditedMessage
+	if msg == nil || msg.Chat.Type != "supergroup" {
+		return
+	}
+
+	// Платные объявления публикует сам бот, поэтому любая правка
+	// пользовательского сообщения в платной теме — обход оплаты
+	if msg.MessageThreadID != 0 {
+		topic, err := h.db.GetTopicByGroupAndTopicID(ctx, msg.Chat.ID, msg.MessageThreadID)
+		if err == nil && topic.IsActive {
+			switch {
+			case msg.SenderChat != nil:
+				h.onPaidTopicSender(ctx, msg)
+			case msg.From != nil && !msg.From.IsBot:
+				h.onServicesTopicEdit(ctx, msg, topic)
+			}
+			return
+		}
+	}
+
+	h.onGroupMessage(ctx, msg)
+}
+
 func (h *Handler) OnCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
 	if update.CallbackQuery == nil {
 		return
@@ -131,6 +181,36 @@ func (h *Handler) OnCallback(ctx context.Context, b *bot.Bot, update *models.Upd
 		return
 	}
 
+	// Отложенная публикация: schedule_publish, sched_*
+	if cb.Data == "schedule_publish" || strings.HasPrefix(cb.Data, "sched_") {
+		h.handleScheduleCallback(ctx, cb)
+		return
+	}
+
+	// Решение модератора по теневому срабатыванию (кнопки в лог-канале)
+	if strings.HasPrefix(cb.Data, "shadow_") {
+		h.handleShadowVerdict(ctx, cb)
+		return
+	}
+
+	// Капча нового участника: captcha_<verification_id>_<ответ>
+	if strings.HasPrefix(cb.Data, "captcha_") {
+		h.handleCaptchaCallback(ctx, cb)
+		return
+	}
+
+	// Задержанные сообщения новичков: решения модераторов в лог-канале
+	if strings.HasPrefix(cb.Data, "hold_") {
+		h.handleHoldCallback(ctx, cb)
+		return
+	}
+
+	// Апелляции на удалённые сообщения: кнопка пользователя и решения модераторов
+	if strings.HasPrefix(cb.Data, "appeal_") {
+		h.handleAppealCallback(ctx, cb)
+		return
+	}
+
 	// Формат: skip_email_<topic_id>
 	if strings.HasPrefix(cb.Data, "skip_email_") {
 		topicIDStr := strings.TrimPrefix(cb.Data, "skip_email_")
@@ -233,7 +313,19 @@ func (h *Handler) handleConfirmPublish(ctx context.Context, cb *models.CallbackQ
 
 	// Публикуем
 	h.send(ctx, userID, messages.MsgContentAccepted)
-	h.publishPost(ctx, userID, user, topic, content)
+	if _, err := h.publishPost(ctx, userID, topic, content); err != nil {
+		log.Printf("Ошибка публикации: %v", err)
+		h.send(ctx, userID, messages.MsgError)
+		// Возвращаем в состояние ожидания контента
+		_ = h.db.UpdateUserState(ctx, userID, database.StateWaitingContent, user.CurrentTopicID)
+		return
+	}
+
+	// Очищаем контент и сбрасываем состояние
+	h.clearPendingContent(userID)
+	_ = h.db.ResetUser(ctx, userID)
+
+	h.send(ctx, userID, messages.FormatPublished(topic.DurationDays))
 }
 
 // handleReloadContent обрабатывает запрос на повторную загрузку
@@ -266,8 +358,9 @@ func (h *Handler) handleReloadContent(ctx context.Context, cb *models.CallbackQu
 	h.send(ctx, userID, messages.FormatReloadContent(topic.MaxPhotos))
 }
 
-// publishPost публикует объявление в группу
-func (h *Handler) publishPost(ctx context.Context, userID int64, user *database.User, topic *database.Topic, content *PendingContent) {
+// publishPost публикует объявление в группу и сохраняет пост.
+// Срок размещения отсчитывается от момента публикации.
+func (h *Handler) publishPost(ctx context.Context, userID int64, topic *database.Topic, content *PendingContent) (*database.Post, error) {
 	formattedText := h.formatPostFromContent(userID, content)
 	var sentMsg *models.Message
 	var allMessageIDs []int
@@ -326,26 +419,27 @@ func (h *Handler) publishPost(ctx context.Context, userID int64, user *database.
 	}
 
 	if err != nil {
-		log.Printf("Ошибка публикации: %v", err)
-		h.send(ctx, userID, messages.MsgError)
-		// Возвращаем в состояние ожидания контента
-		_ = h.db.UpdateUserState(ctx, userID, database.StateWaitingContent, user.CurrentTopicID)
-		return
+		return nil, err
 	}
 
 	// Сохраняем пост (проверяем что sentMsg не nil)
+	var post *database.Post
 	if sentMsg != nil {
 		expires := time.Now().Add(time.Duration(topic.DurationDays) * 24 * time.Hour)
-		_, _ = h.db.CreatePost(ctx, sentMsg.ID, allMessageIDs, topic.ID, userID, &content.Text, content.PhotoIDs, expires)
+		post, err = h.db.CreatePost(ctx, sentMsg.ID, allMessageIDs, topic.ID, userID, &content.Text, content.PhotoIDs, expires)
+		if err != nil {
+			// Пост уже в группе — ошибку только логируем
+			log.Printf("Ошибка сохранения поста: %v", err)
+			post = nil
+		} else {
+			h.scheduleExpiry(ctx, post.ID, expires)
+			h.hashPostPhotos(ctx, post, topic)
+		}
 	}
 
-	// Очищаем контент и сбрасываем состояние
-	h.clearPendingContent(userID)
-	_ = h.db.ResetUser(ctx, userID)
-
 	tglog.Send("📝 Опубликовано от user %d — тема «%s» (фото: %d, срок: %d дн.)", userID, topic.Title, len(content.PhotoIDs), topic.DurationDays)
 
-	h.send(ctx, userID, messages.FormatPublished(topic.DurationDays))
+	return post, nil
 }
 
 // formatPostFromContent форматирует пост из сохранённого контента
@@ -419,13 +513,23 @@ func (h *Handler) onServicesTopicMessage(ctx context.Context, msg *models.Messag
 	}
 
 	// Удаляем предупреждение через 60 сек
-	go func() {
-		time.Sleep(60 * time.Second)
-		_, _ = h.bot.DeleteMessage(context.Background(), &bot.DeleteMessageParams{
-			ChatID:    msg.Chat.ID,
-			MessageID: warning.ID,
-		})
-	}()
+	h.deleteMessageLater(ctx, msg.Chat.ID, warning.ID, 60*time.Second)
+}
+
+// onServicesTopicEdit удаляет отредактированное сообщение в платной теме.
+// Кнопку оплаты не дублируем — она уже была показана при отправке.
+func (h *Handler) onServicesTopicEdit(ctx context.Context, msg *models.Message, topic *database.Topic) {
+	_, err := h.bot.DeleteMessage(ctx, &bot.DeleteMessageParams{
+		ChatID:    msg.Chat.ID,
+		MessageID: msg.ID,
+	})
+	if err != nil {
+		log.Printf("Ошибка удаления отредактированного сообщения: %v", err)
+		return
+	}
+
+	log.Printf("Удалена правка сообщения %d от user=%d в платной теме «%s»", msg.ID, msg.From.ID, topic.Title)
+	tglog.Send("✏️ Удалена правка сообщения от %s (id: %d) в платной теме «%s»", html.EscapeString(msg.From.FirstName), msg.From.ID, html.EscapeString(topic.Title))
 }
 
 func (h *Handler) onPrivateMessage(ctx context.Context, msg *models.Message) {
@@ -494,6 +598,27 @@ func (h *Handler) onPrivateMessage(ctx context.Context, msg *models.Message) {
 		return
 	}
 
+	// /start extend_<post_id> — продление из напоминания
+	if strings.HasPrefix(msg.Text, "/start extend_") {
+		postID, err := strconv.Atoi(strings.TrimPrefix(msg.Text, "/start extend_"))
+		if err != nil {
+			return
+		}
+		h.startExtend(ctx, userID, postID)
+		return
+	}
+
+	// Список отложенных публикаций
+	if strings.HasPrefix(msg.Text, "/scheduled") {
+		h.sendScheduledList(ctx, userID)
+		return
+	}
+
+	// Команды администраторов
+	if h.isAdmin(userID) && h.onAdminCommand(ctx, msg) {
+		return
+	}
+
 	// Обычный /start
 	if strings.HasPrefix(msg.Text, "/start") {
 		h.send(ctx, userID, "👋 Для размещения объявления напишите в соответствующую тему группы.")
@@ -601,6 +726,11 @@ func (h *Handler) onPaymentSuccess(ctx context.Context, msg *models.Message) {
 
 	log.Printf("Оплата: user=%d amount=%d %s", userID, p.TotalAmount, p.Currency)
 
+	if strings.HasPrefix(p.InvoicePayload, "extend_") {
+		h.onExtendPaymentSuccess(ctx, msg)
+		return
+	}
+
 	// Получаем пользователя
 	user, err := h.db.GetUser(ctx, userID)
 	if err != nil || user.CurrentTopicID == nil {
@@ -820,15 +950,10 @@ func (h *Handler) setPreviewMessageIDs(userID int64, msgIDs []int) {
 // deletePreviewMessages удаляет сообщения media group из превью
 func (h *Handler) deletePreviewMessages(ctx context.Context, userID int64) {
 	content := h.getPendingContent(userID)
-	if content == nil {
+	if content == nil || len(content.PreviewMessageIDs) == 0 {
 		return
 	}
-	for _, msgID := range content.PreviewMessageIDs {
-		_, _ = h.bot.DeleteMessage(ctx, &bot.DeleteMessageParams{
-			ChatID:    userID,
-			MessageID: msgID,
-		})
-	}
+	h.deleteMessagesBulk(ctx, map[int64][]int{userID: content.PreviewMessageIDs})
 }
 
 // showPreview показывает предпросмотр объявления
@@ -854,6 +979,11 @@ func (h *Handler) showPreview(ctx context.Context, userID int64, user *database.
 		previewText += fmt.Sprintf("📷 Фото: %d шт.\n\n", len(content.PhotoIDs))
 	}
 
+	// Отложенная публикация — только без ручной модерации
+	if topic.ModerationEnabled {
+		previewText += messages.MsgScheduleModerated + "\n\n"
+	}
+
 	previewText += "Подтвердите публикацию или загрузите заново."
 
 	// Отправляем предпросмотр с кнопками
@@ -865,6 +995,11 @@ func (h *Handler) showPreview(ctx context.Context, userID int64, user *database.
 			},
 		},
 	}
+	if !topic.ModerationEnabled {
+		keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, []models.InlineKeyboardButton{
+			{Text: "🕒 Опубликовать позже", CallbackData: "schedule_publish"},
+		})
+	}
 
 	if len(content.PhotoIDs) > 1 {
 		// Несколько фото — отправляем media group, затем текст с кнопками
@@ -951,98 +1086,120 @@ func (h *Handler) send(ctx context.Context, chatID int64, text string) {
 	_, _ = h.bot.SendMessage(ctx, &bot.SendMessageParams{ChatID: chatID, Text: text})
 }
 
-func (h *Handler) DeleteExpiredPosts(ctx context.Context) {
-	posts, err := h.db.GetExpiredPosts(ctx)
+// expirePost снимает пост с публикации по истечении срока.
+// Статус удаления хранится для каждого сообщения поста: пост считается
+// удалённым, только когда в чате не осталось ни одного его сообщения.
+func (h *Handler) expirePost(ctx context.Context, job *database.Job, payload jobs.ExpirePost) error {
+	p, err := h.db.GetExpiredPost(ctx, payload.PostID)
+	if isNotFound(err) {
+		// Уже удалён или помечен как неудаляемый
+		return nil
+	}
 	if err != nil {
-		log.Printf("Ошибка получения просроченных постов: %v", err)
-		return
+		return err
+	}
+
+	// Удаляем все сообщения поста (media group или одиночное)
+	msgIDs := p.AllMessageIDs
+	if len(msgIDs) == 0 {
+		// Для старых постов без all_message_ids
+		msgIDs = []int{p.MessageID}
+	}
+	deletions, err := h.db.GetPostMessageDeletions(ctx, p.ID, p.ChatID, msgIDs)
+	if err != nil {
+		return err
 	}
 
-	for _, p := range posts {
-		// Удаляем все сообщения поста (media group или одиночное)
-		msgIDs := p.AllMessageIDs
-		if len(msgIDs) == 0 {
-			// Для старых постов без all_message_ids
-			msgIDs = []int{p.MessageID}
+	byChat := make(map[int64][]int)
+	for _, d := range deletions {
+		if !d.IsFinal() {
+			byChat[d.ChatID] = append(byChat[d.ChatID], d.MessageID)
 		}
-		for _, msgID := range msgIDs {
-			_, err := h.bot.DeleteMessage(ctx, &bot.DeleteMessageParams{
-				ChatID:    p.ChatID,
-				MessageID: msgID,
-			})
-			if err != nil {
-				log.Printf("Ошибка удаления сообщения %d: %v", msgID, err)
-			}
+	}
+	failed := h.deleteMessagesBulk(ctx, byChat)
+
+	remaining := 0
+	var retryAfter time.Duration
+	for i := range deletions {
+		d := &deletions[i]
+		if d.IsFinal() {
+			continue
 		}
 
-		_ = h.db.MarkPostDeleted(ctx, p.ID)
-		log.Printf("Удалён пост %d (chat=%d, сообщений: %d)", p.MessageID, p.ChatID, len(msgIDs))
+		err := failed[chatMessage{d.ChatID, d.MessageID}]
+		status, delay := classifyDeleteError(err)
 
-		// Напоминание пользователю о переопубликации
-		topic, err := h.db.GetTopicByID(ctx, p.InternalTopicID)
+		var lastError *string
 		if err != nil {
-			log.Printf("Ошибка получения темы %d: %v", p.InternalTopicID, err)
-			continue
+			log.Printf("Ошибка удаления сообщения %d (%s): %v", d.MessageID, status, err)
+			e := err.Error()
+			lastError = &e
 		}
+		if err := h.db.UpdatePostMessageDeletion(ctx, p.ID, d.MessageID, status, lastError); err != nil {
+			log.Printf("Ошибка сохранения статуса удаления %d: %v", d.MessageID, err)
+		}
+		d.Status, d.LastError = status, lastError
 
-		tglog.Send("🗑 Удалён просроченный пост от user %d — тема «%s»", p.UserID, topic.Title)
-
-		_, _ = h.bot.SendMessage(ctx, &bot.SendMessageParams{
-			ChatID: p.UserID,
-			Text:   messages.FormatExpiredReminder(topic.Title, topic.Price, topic.DurationDays),
-			ReplyMarkup: &models.InlineKeyboardMarkup{
-				InlineKeyboard: [][]models.InlineKeyboardButton{{
-					{Text: "🔄 Разместить заново", URL: fmt.Sprintf("https://t.me/%s?start=pay_%d", h.botUsername, topic.ID)},
-				}},
-			},
-		})
+		if !d.IsFinal() {
+			remaining++
+			retryAfter = max(retryAfter, delay)
+		}
 	}
-}
 
-func (h *Handler) handleSpamViolation(ctx context.Context, msg *models.Message, violation *moderation.Violation) {
-	// Удаляем сообщение
-	_, err := h.bot.DeleteMessage(ctx, &bot.DeleteMessageParams{
-		ChatID:    msg.Chat.ID,
-		MessageID: msg.ID,
-	})
+	topic, err := h.db.GetTopicByID(ctx, p.InternalTopicID)
 	if err != nil {
-		log.Printf("Ошибка удаления спам-сообщения: %v", err)
+		return fmt.Errorf("тема %d: %w", p.InternalTopicID, err)
 	}
 
-	// Сохраняем нарушение в БД
-	text := msg.Text
-	if msg.Caption != "" {
-		text = msg.Caption
+	if remaining > 0 {
+		p.DeletionAttempts, err = h.db.IncrementPostDeletionAttempts(ctx, p.ID)
+		if err != nil {
+			return err
+		}
+		if p.DeletionAttempts >= h.cfg.DeleteMaxAttempts {
+			log.Printf("Пост %d не удалён после %d попыток", p.ID, p.DeletionAttempts)
+			alertPostDeletionFailed(p, topic.Title, deletions)
+			return h.db.MarkPostDeletionFailed(ctx, p.ID)
+		}
+
+		delay := max(jobs.Backoff(p.DeletionAttempts), retryAfter)
+		log.Printf("Пост %d: не удалено сообщений: %d, повтор через %s", p.ID, remaining, delay)
+		return h.queue.Enqueue(ctx, jobs.TypeExpirePost, fmt.Sprintf("expire_post:%d", p.ID), payload, time.Now().Add(delay))
 	}
-	var topicID *int
-	if msg.MessageThreadID != 0 {
-		topicID = &msg.MessageThreadID
+
+	if err := h.db.MarkPostDeleted(ctx, p.ID); err != nil {
+		return err
 	}
-	_ = h.db.CreateSpamViolation(ctx, msg.From.ID, msg.Chat.ID, topicID, text, string(violation.Type), violation.Match)
+	log.Printf("Удалён пост %d (chat=%d, сообщений: %d)", p.MessageID, p.ChatID, len(msgIDs))
 
-	// Отправляем предупреждение в топик
-	warning, err := h.bot.SendMessage(ctx, &bot.SendMessageParams{
-		ChatID:          msg.Chat.ID,
-		MessageThreadID: msg.MessageThreadID,
-		Text:            messages.FormatSpamWarning(msg.From.ID, msg.From.FirstName),
-		ParseMode:       models.ParseModeHTML,
-	})
+	// Напоминание пользователю о переопубликации
+	reminder := jobs.ExpiredReminder{UserID: p.UserID, TopicID: topic.ID}
+	if err := h.queue.Enqueue(ctx, jobs.TypeExpiredReminder, "", reminder, time.Now()); err != nil {
+		log.Printf("Ошибка постановки напоминания для поста %d: %v", p.ID, err)
+	}
+	return nil
+}
+
+// sendExpiredReminder сообщает продавцу, что пост удалён, и предлагает разместить заново
+func (h *Handler) sendExpiredReminder(ctx context.Context, job *database.Job, payload jobs.ExpiredReminder) error {
+	topic, err := h.db.GetTopicByID(ctx, payload.TopicID)
 	if err != nil {
-		log.Printf("Ошибка отправки предупреждения: %v", err)
-		return
+		return fmt.Errorf("тема %d: %w", payload.TopicID, err)
 	}
 
-	// Удаляем предупреждение через 30 сек
-	go func() {
-		time.Sleep(30 * time.Second)
-		_, _ = h.bot.DeleteMessage(context.Background(), &bot.DeleteMessageParams{
-			ChatID:    msg.Chat.ID,
-			MessageID: warning.ID,
-		})
-	}()
+	tglog.Send("🗑 Удалён просроченный пост от user %d — тема «%s»", payload.UserID, topic.Title)
 
-	log.Printf("Спам от user=%d: type=%s match=%s", msg.From.ID, violation.Type, violation.Match)
-	tglog.Send("🚫 Спам от %s (id: %d) — %s: %s", msg.From.FirstName, msg.From.ID, violation.Type, violation.Match)
+	// Пользователь мог заблокировать бота — не повторяем
+	_, _ = h.bot.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: payload.UserID,
+		Text:   messages.FormatExpiredReminder(topic.Title, topic.Price, topic.DurationDays),
+		ReplyMarkup: &models.InlineKeyboardMarkup{
+			InlineKeyboard: [][]models.InlineKeyboardButton{{
+				{Text: "🔄 Разместить заново", URL: fmt.Sprintf("https://t.me/%s?start=pay_%d", h.botUsername, topic.ID)},
+			}},
+		},
+	})
+	return nil
 }
 
 func (h *Handler) LoadAllowedDomains(ctx context.Context) {
@@ -1051,8 +1208,33 @@ func (h *Handler) LoadAllowedDomains(ctx context.Context) {
 		log.Printf("Ошибка загрузки разрешённых доменов: %v", err)
 		return
 	}
-	h.allowedDomains = domains
-	log.Printf("Загружено %d разрешённых доменов", len(domains))
+	allowlist := moderation.NewAllowlist(domains)
+	h.moderationMu.Lock()
+	h.allowlist = allowlist
+	h.moderationMu.Unlock()
+	log.Printf("Загружено %d правил белого списка ссылок", len(allowlist))
+}
+
+// currentAllowlist — белый список ссылок, загруженный последним
+func (h *Handler) currentAllowlist() moderation.Allowlist {
+	h.moderationMu.RLock()
+	defer h.moderationMu.RUnlock()
+	return h.allowlist
+}
+
+// isServiceMessage — служебное сообщение группы, а не сообщение участника
+func isServiceMessage(msg *models.Message) bool {
+	return len(msg.NewChatMembers) > 0 || msg.LeftChatMember != nil ||
+		msg.NewChatTitle != "" || len(msg.NewChatPhoto) > 0 || msg.DeleteChatPhoto ||
+		msg.PinnedMessage != nil || msg.MessageAutoDeleteTimerChanged != nil ||
+		msg.MigrateFromChatID != 0 || msg.MigrateToChatID != 0 ||
+		msg.ForumTopicCreated != nil || msg.ForumTopicEdited != nil ||
+		msg.ForumTopicClosed != nil || msg.ForumTopicReopened != nil ||
+		msg.GeneralForumTopicHidden != nil || msg.GeneralForumTopicUnhidden != nil ||
+		msg.BoostAdded != nil || msg.ChatBackgroundSet != nil || msg.ProximityAlertTriggered != nil ||
+		msg.VoiceChatScheduled != nil || msg.VoiceChatStarted != nil || msg.VoiceChatEnded != nil ||
+		msg.VoiceChatParticipantsInvited != nil || msg.WriteAccessAllowed != nil ||
+		msg.GiveawayCreated != nil || msg.GiveawayWinners != nil || msg.GiveawayCompleted != nil
 }
 
 // Хелпер для указателя на строку
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg" // фото в Telegram — JPEG
+	_ "image/png"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+)
+
+// Больше 10 МБ бот не скачивает
+const maxImageSize = 10 << 20
+
+var fileClient = &http.Client{Timeout: 30 * time.Second}
+
+// largestPhoto — самый большой из размеров фото
+func largestPhoto(photos []models.PhotoSize) models.PhotoSize {
+	best := photos[0]
+	for _, p := range photos[1:] {
+		if p.Width*p.Height > best.Width*best.Height {
+			best = p
+		}
+	}
+	return best
+}
+
+// downloadImage скачивает файл через getFile и декодирует изображение
+func (h *Handler) downloadImage(ctx context.Context, fileID string) (image.Image, error) {
+	file, err := h.bot.GetFile(ctx, &bot.GetFileParams{FileID: fileID})
+	if err != nil {
+		return nil, err
+	}
+	return h.fetchImage(ctx, file)
+}
+
+// fetchImage скачивает файл, уже полученный через getFile
+func (h *Handler) fetchImage(ctx context.Context, file *models.File) (image.Image, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, h.bot.FileDownloadLink(file), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := fileClient.Do(req)
+	if err != nil {
+		// В ссылке на файл — токен бота, в лог она попасть не должна
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return nil, fmt.Errorf("скачивание %s: %w", file.FilePath, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("скачивание %s: %s", file.FilePath, resp.Status)
+	}
+
+	img, _, err := image.Decode(io.LimitReader(resp.Body, maxImageSize))
+	if err != nil {
+		return nil, fmt.Errorf("декодирование %s: %w", file.FilePath, err)
+	}
+	return img, nil
+}
@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"go_payment_bot/database"
+	"go_payment_bot/jobs"
+
+	"github.com/go-telegram/bot"
+)
+
+const (
+	cleanupJobsInterval = 24 * time.Hour
+	// Сколько хранить выполненные задачи
+	finishedJobsTTL = 7 * 24 * time.Hour
+	// Как часто все инстансы перечитывают белый список, правила и модель
+	reloadModerationInterval = time.Hour
+	// Пауза перед повторной подпиской на перезагрузку после обрыва соединения
+	reloadListenRetry = 15 * time.Second
+)
+
+// RegisterJobs регистрирует обработчики задач очереди
+func (h *Handler) RegisterJobs(q *jobs.Queue) {
+	q.Register(jobs.TypeDeleteMessage, jobs.Handle(h.deleteMessageJob))
+	q.Register(jobs.TypeExpirePost, jobs.Handle(h.expirePost))
+	q.Register(jobs.TypeExpiredReminder, jobs.Handle(h.sendExpiredReminder))
+	q.Register(jobs.TypeExpiryReminder, jobs.Handle(h.sendExpiryReminder))
+	q.Register(jobs.TypePublishScheduled, jobs.Handle(h.publishScheduledPost))
+	q.Register(jobs.TypeCleanupJobs, jobs.Handle(h.cleanupJobsJob))
+	q.Register(jobs.TypeReloadModeration, jobs.Handle(h.reloadModerationJob))
+	q.Register(jobs.TypeCaptchaTimeout, jobs.Handle(h.captchaTimeoutJob))
+	q.Register(jobs.TypePhotoHash, jobs.Handle(h.photoHashJob))
+}
+
+// ScheduleRecurringJobs ставит периодические задачи (если их ещё нет в очереди).
+// Вызывается при каждом получении лидерства, поэтому уже запланированные
+// запуски не переносятся.
+func (h *Handler) ScheduleRecurringJobs(ctx context.Context) {
+	if err := h.queue.EnqueueOnce(ctx, jobs.TypeCleanupJobs, string(jobs.TypeCleanupJobs), jobs.Empty{}, time.Now().Add(cleanupJobsInterval)); err != nil {
+		log.Printf("Ошибка постановки очистки задач: %v", err)
+	}
+	if err := h.queue.EnqueueOnce(ctx, jobs.TypeReloadModeration, string(jobs.TypeReloadModeration), jobs.Empty{}, time.Now().Add(reloadModerationInterval)); err != nil {
+		log.Printf("Ошибка постановки перезагрузки правил: %v", err)
+	}
+}
+
+// deleteMessageLater удаляет сообщение через delay (переживает перезапуск)
+func (h *Handler) deleteMessageLater(ctx context.Context, chatID int64, messageID int, delay time.Duration) {
+	payload := jobs.DeleteMessage{ChatID: chatID, MessageID: messageID}
+	if err := h.queue.Enqueue(ctx, jobs.TypeDeleteMessage, "", payload, time.Now().Add(delay)); err != nil {
+		log.Printf("Ошибка постановки удаления сообщения %d: %v", messageID, err)
+	}
+}
+
+func (h *Handler) deleteMessageJob(ctx context.Context, job *database.Job, payload jobs.DeleteMessage) error {
+	_, err := h.bot.DeleteMessage(ctx, &bot.DeleteMessageParams{
+		ChatID:    payload.ChatID,
+		MessageID: payload.MessageID,
+	})
+	if status, _ := classifyDeleteError(err); status != database.MessageDeletionPending {
+		// Удалено, уже нет или нет прав — повторять бессмысленно
+		if err != nil {
+			log.Printf("Сообщение %d не удалено (%s): %v", payload.MessageID, status, err)
+		}
+		return nil
+	}
+	return err
+}
+
+func (h *Handler) cleanupJobsJob(ctx context.Context, job *database.Job, _ jobs.Empty) error {
+	n, err := h.db.DeleteFinishedJobs(ctx, time.Now().Add(-finishedJobsTTL))
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		log.Printf("Удалено %d выполненных задач", n)
+	}
+	return h.queue.Enqueue(ctx, jobs.TypeCleanupJobs, string(jobs.TypeCleanupJobs), jobs.Empty{}, time.Now().Add(cleanupJobsInterval))
+}
+
+// reloadModerationJob рассылает всем инстансам сигнал перечитать белый
+// список, правила и модель: кэш свой у каждого инстанса, а задачу
+// выполняет только лидер
+func (h *Handler) reloadModerationJob(ctx context.Context, job *database.Job, _ jobs.Empty) error {
+	if err := h.db.NotifyReload(ctx); err != nil {
+		return err
+	}
+	return h.queue.Enqueue(ctx, jobs.TypeReloadModeration, string(jobs.TypeReloadModeration), jobs.Empty{}, time.Now().Add(reloadModerationInterval))
+}
+
+// ListenReloads перечитывает белый список, правила и модель по сигналу
+// reloadModerationJob. Подписка держится на отдельном соединении; после
+// обрыва кэши перечитываются сразу — сигнал мог быть пропущен.
+// Возвращается после отмены ctx.
+func (h *Handler) ListenReloads(ctx context.Context) {
+	catchUp := false
+	for {
+		err := h.listenReloads(ctx, catchUp)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("Подписка на перезагрузку правил прервана: %v", err)
+		catchUp = true
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(reloadListenRetry):
+		}
+	}
+}
+
+func (h *Handler) listenReloads(ctx context.Context, catchUp bool) error {
+	conn, err := h.db.Pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	// Соединение с LISTEN в пул не возвращаем
+	pgConn := conn.Hijack()
+	defer pgConn.Close(context.Background())
+
+	if _, err := pgConn.Exec(ctx, "LISTEN "+database.ReloadChannel); err != nil {
+		return err
+	}
+	if catchUp {
+		h.reloadModeration(ctx)
+	}
+	for {
+		if _, err := pgConn.WaitForNotification(ctx); err != nil {
+			return err
+		}
+		h.reloadModeration(ctx)
+	}
+}
+
+// notifyReload даёт всем инстансам сигнал перечитать правила после изменения в БД
+func (h *Handler) notifyReload(ctx context.Context) {
+	if err := h.db.NotifyReload(ctx); err != nil {
+		log.Printf("Ошибка оповещения инстансов о перезагрузке правил: %v", err)
+	}
+}
+
+// reloadModeration перечитывает белый список ссылок, правила спама и модель классификатора
+func (h *Handler) reloadModeration(ctx context.Context) {
+	h.LoadAllowedDomains(ctx)
+	h.LoadSpamRules(ctx)
+	h.LoadBayes(ctx)
+}
@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"go_payment_bot/database"
+	"go_payment_bot/messages"
+	"go_payment_bot/moderation"
+	"go_payment_bot/tglog"
+
+	"github.com/go-telegram/bot/models"
+)
+
+// Нарушения ограничений для новичков в spam_violations.violation_type
+const (
+	violationNewcomerLink    = "newcomer_link"
+	violationNewcomerCaption = "newcomer_caption"
+	violationNewcomerHold    = "newcomer_hold"
+)
+
+// Действие для задержанного сообщения в spam_violations.action
+const actionHold = "hold"
+
+// checkNewcomer учитывает сообщение участника и применяет к новичкам
+// ограничения группы. Возвращает true, если сообщение удалено или задержано.
+func (h *Handler) checkNewcomer(ctx context.Context, msg *models.Message) bool {
+	group := h.groupPolicies(ctx, msg.Chat.ID)
+
+	var member *database.GroupMember
+	var err error
+	if msg.EditDate == 0 {
+		member, err = h.db.RecordGroupMessage(ctx, msg.From.ID, msg.Chat.ID)
+	} else {
+		member, err = h.db.GetGroupMember(ctx, msg.From.ID, msg.Chat.ID)
+	}
+	if err != nil {
+		if !isNotFound(err) {
+			log.Printf("Ошибка учёта сообщения user=%d в chat=%d: %v", msg.From.ID, msg.Chat.ID, err)
+		}
+		return false
+	}
+	if !isNewcomer(group, member) {
+		return false
+	}
+
+	violation, match := newcomerViolation(group, msg)
+	if violation == "" && !(group.NewcomerHold && moderation.MessageText(msg) != "") {
+		return false
+	}
+
+	// Администраторов, доверенных участников и клиентов не ограничиваем
+	if level, reason := h.exemption(ctx, msg); level != exemptNone {
+		log.Printf("Ограничения новичка не применены к user=%d в chat=%d (%s)", msg.From.ID, msg.Chat.ID, reason)
+		return false
+	}
+
+	if violation != "" {
+		h.blockSenderMessage(ctx, msg, violation, match)
+		h.sendSpamWarning(ctx, msg, messages.FormatNewcomerRestricted(msg.From.ID, msg.From.FirstName))
+		return true
+	}
+	h.holdMessage(ctx, msg)
+	return true
+}
+
+// isNewcomer — участник ещё не написал в группе newcomer_messages сообщений
+// без нарушений или провёл в ней меньше newcomer_hours часов
+func isNewcomer(group *database.Group, m *database.GroupMember) bool {
+	if group.NewcomerMessages > 0 && m.MessageCount-m.Violations <= group.NewcomerMessages {
+		return true
+	}
+	if group.NewcomerHours > 0 && time.Since(m.FirstSeenAt) < time.Duration(group.NewcomerHours)*time.Hour {
+		return true
+	}
+	return false
+}
+
+// newcomerViolation — что в сообщении запрещено новичкам: любые ссылки,
+// в том числе t.me, @боты и email (белый список не действует), и фото с подписью.
+// Упоминать участников группы новичкам можно.
+func newcomerViolation(group *database.Group, msg *models.Message) (string, string) {
+	if group.NewcomerNoLinks {
+		for _, s := range moderation.AnalyzeMessage(msg, nil) {
+			switch s.Type {
+			case moderation.SignalLink, moderation.SignalContact, moderation.SignalEmail:
+				return violationNewcomerLink, s.Match
+			}
+		}
+	}
+	if group.NewcomerNoCaptions && len(msg.Photo) > 0 && msg.Caption != "" {
+		return violationNewcomerCaption, "photo"
+	}
+	return "", ""
+}
+
+// holdMessage снимает сообщение новичка с публикации до решения модератора:
+// текст сохраняется как нарушение и отправляется в лог-канал с кнопками
+func (h *Handler) holdMessage(ctx context.Context, msg *models.Message) {
+	h.deleteSpamMessage(ctx, msg)
+
+	text := moderation.MessageText(msg)
+	v := &database.SpamViolation{
+		UserID:        msg.From.ID,
+		GroupID:       msg.Chat.ID,
+		MessageText:   &text,
+		ViolationType: violationNewcomerHold,
+		Action:        actionHold,
+	}
+	if len(msg.Photo) > 0 {
+		photo := largestPhoto(msg.Photo).FileID
+		v.PhotoFileID = &photo
+	}
+	setSenderFields(v, msg)
+	id, err := h.db.CreateSpamViolation(ctx, v)
+	if err != nil {
+		log.Printf("Ошибка сохранения задержанного сообщения user=%d: %v", msg.From.ID, err)
+		return
+	}
+
+	// Имя нужно для подписи при публикации
+	_, _ = h.db.GetOrCreateUser(ctx, msg.From.ID, ptrStr(msg.From.Username), ptrStr(msg.From.FirstName), ptrStr(msg.From.LastName))
+
+	h.sendSpamWarning(ctx, msg, messages.FormatNewcomerHeld(msg.From.ID, msg.From.FirstName))
+
+	log.Printf("Сообщение новичка user=%d в chat=%d задержано до проверки (#%d)%s", msg.From.ID, msg.Chat.ID, id, editSuffix(msg))
+	tglog.SendWithMarkup(&models.InlineKeyboardMarkup{
+		InlineKeyboard: [][]models.InlineKeyboardButton{{
+			{Text: "✅ Опубликовать", CallbackData: fmt.Sprintf("hold_approve_%d", id)},
+			{Text: "🚫 Отклонить", CallbackData: fmt.Sprintf("hold_reject_%d", id)},
+		}},
+	}, "⏳ Сообщение новичка #%d: %s (id: %d) в группе %s%s\n\n%s", id,
+		html.EscapeString(msg.From.FirstName), msg.From.ID, html.EscapeString(msg.Chat.Title), editSuffix(msg),
+		html.EscapeString(truncateRunes(text, appealTextLimit)))
+}
+
+// handleHoldCallback — решение модератора по задержанному сообщению.
+// Формат: hold_<approve|reject>_<violation_id>
+func (h *Handler) handleHoldCallback(ctx context.Context, cb *models.CallbackQuery) {
+	if !h.isAdmin(cb.From.ID) {
+		return
+	}
+	parts := strings.Split(cb.Data, "_")
+	if len(parts) != 3 {
+		return
+	}
+	id, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return
+	}
+
+	// Одобренное сообщение не считается нарушением, отклонённое — считается
+	var verdict string
+	switch parts[1] {
+	case "approve":
+		verdict = database.ModVerdictHam
+	case "reject":
+		verdict = database.ModVerdictSpam
+	default:
+		return
+	}
+
+	ok, err := h.db.ResolveHeldMessage(ctx, id, verdict, cb.From.ID)
+	if err != nil {
+		log.Printf("Ошибка сохранения решения по сообщению #%d: %v", id, err)
+		return
+	}
+	if !ok {
+		return
+	}
+	h.learnBayes(ctx, id, verdict)
+
+	result := "🚫 Отклонено"
+	if verdict == database.ModVerdictHam {
+		result = "✅ Опубликовано"
+		v, err := h.db.GetSpamViolation(ctx, id)
+		if err == nil {
+			err = h.restoreMessage(ctx, v, messages.FormatApprovedMessage)
+		}
+		if err != nil {
+			log.Printf("Ошибка публикации сообщения #%d: %v", id, err)
+			result += " (не удалось отправить в группу)"
+		}
+	}
+
+	log.Printf("Задержанное сообщение #%d: %s, модератор %d", id, verdict, cb.From.ID)
+	if msg := cb.Message.Message; msg != nil {
+		h.markResolved(ctx, msg, result, cb.From.FirstName)
+	}
+}
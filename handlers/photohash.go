@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"go_payment_bot/database"
+	"go_payment_bot/jobs"
+	"go_payment_bot/moderation"
+	"go_payment_bot/tglog"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+)
+
+const (
+	// Сколько похожих фото других пользователей показывать модераторам
+	similarPhotosLimit = 3
+	// За какой срок ищутся похожие фото: сравниваются только хэши
+	// из той же группы, чтобы поиск не перебирал всю таблицу
+	similarPhotosWindow = 90 * 24 * time.Hour
+)
+
+// hashPostPhotos ставит в очередь хэширование фото опубликованного объявления.
+// Фото media group публикуются отдельными сообщениями в том же порядке.
+func (h *Handler) hashPostPhotos(ctx context.Context, post *database.Post, topic *database.Topic) {
+	payload := jobs.PhotoHash{
+		UserID:   post.UserID,
+		ChatID:   topic.GroupID,
+		ThreadID: topic.TopicID,
+		PostID:   post.ID,
+	}
+	for i, fileID := range post.PhotoFileIDs {
+		if i >= len(post.AllMessageIDs) {
+			break
+		}
+		payload.Photos = append(payload.Photos, jobs.PhotoRef{FileID: fileID, MessageID: post.AllMessageIDs[i]})
+	}
+	h.enqueuePhotoHash(ctx, payload)
+}
+
+// hashGroupPhoto ставит в очередь хэширование фото из сообщения в обычной теме
+func (h *Handler) hashGroupPhoto(ctx context.Context, msg *models.Message) {
+	if len(msg.Photo) == 0 || msg.EditDate != 0 {
+		return
+	}
+	// В группе без тем message_thread_id у ответов — ID исходного сообщения
+	threadID := 0
+	if msg.IsTopicMessage {
+		threadID = msg.MessageThreadID
+	}
+	h.enqueuePhotoHash(ctx, jobs.PhotoHash{
+		UserID:   msg.From.ID,
+		ChatID:   msg.Chat.ID,
+		ThreadID: threadID,
+		Photos:   []jobs.PhotoRef{{FileID: largestPhoto(msg.Photo).FileID, MessageID: msg.ID}},
+	})
+}
+
+// enqueuePhotoHash — скачивание фото не должно задерживать публикацию и модерацию
+func (h *Handler) enqueuePhotoHash(ctx context.Context, payload jobs.PhotoHash) {
+	if len(payload.Photos) == 0 {
+		return
+	}
+	if err := h.queue.Enqueue(ctx, jobs.TypePhotoHash, "", payload, time.Now()); err != nil {
+		log.Printf("Ошибка постановки хэширования фото user=%d: %v", payload.UserID, err)
+	}
+}
+
+// photoHashJob считает хэши фото, ищет похожие фото других пользователей
+// и сохраняет хэши. Задача повторяется, только пока не посчитаны все хэши,
+// поэтому записи при повторе не дублируются.
+func (h *Handler) photoHashJob(ctx context.Context, job *database.Job, payload jobs.PhotoHash) error {
+	hashes := make([]database.PhotoHash, 0, len(payload.Photos))
+	for _, photo := range payload.Photos {
+		uniqueID, hash, err := h.photoHash(ctx, photo.FileID)
+		if err != nil {
+			return fmt.Errorf("хэш фото в сообщении %d: %w", photo.MessageID, err)
+		}
+		p := database.PhotoHash{
+			FileUniqueID: uniqueID,
+			Hash:         hash,
+			UserID:       payload.UserID,
+			ChatID:       payload.ChatID,
+			ThreadID:     payload.ThreadID,
+			MessageID:    photo.MessageID,
+		}
+		if payload.PostID != 0 {
+			p.PostID = &payload.PostID
+		}
+		hashes = append(hashes, p)
+	}
+
+	// Похожие ищем до сохранения, чтобы фото одного объявления не совпадали друг с другом
+	since := time.Now().Add(-similarPhotosWindow)
+	seen := make(map[int]bool)
+	var similar []database.PhotoHash
+	for _, p := range hashes {
+		found, err := h.db.FindSimilarPhotos(ctx, p.Hash, payload.UserID, payload.ChatID, since,
+			moderation.SimilarPhotoDistance, similarPhotosLimit)
+		if err != nil {
+			return err
+		}
+		for _, s := range found {
+			if !seen[s.ID] && len(similar) < similarPhotosLimit {
+				seen[s.ID] = true
+				similar = append(similar, s)
+			}
+		}
+	}
+
+	for i := range hashes {
+		if err := h.db.SavePhotoHash(ctx, &hashes[i]); err != nil {
+			log.Printf("Ошибка сохранения хэша фото user=%d: %v", payload.UserID, err)
+		}
+	}
+
+	if len(similar) > 0 {
+		h.reportSimilarPhotos(payload, similar)
+	}
+	return nil
+}
+
+// photoHash — перцептивный хэш фото. Если тот же файл уже встречался,
+// хэш берётся из БД и фото не скачивается.
+func (h *Handler) photoHash(ctx context.Context, fileID string) (string, uint64, error) {
+	file, err := h.bot.GetFile(ctx, &bot.GetFileParams{FileID: fileID})
+	if err != nil {
+		return "", 0, err
+	}
+
+	hash, err := h.db.GetPhotoHash(ctx, file.FileUniqueID)
+	if err == nil {
+		return file.FileUniqueID, hash, nil
+	}
+	if !isNotFound(err) {
+		return "", 0, err
+	}
+
+	img, err := h.fetchImage(ctx, file)
+	if err != nil {
+		return "", 0, err
+	}
+	return file.FileUniqueID, moderation.PHash(img), nil
+}
+
+// reportSimilarPhotos сообщает в лог-канал о фото, которые раньше выкладывали
+// другие пользователи, со ссылками на их сообщения
+func (h *Handler) reportSimilarPhotos(payload jobs.PhotoHash, similar []database.PhotoHash) {
+	where := fmt.Sprintf(`<a href="%s">сообщении</a>`,
+		postLink(payload.ChatID, payload.ThreadID, payload.Photos[0].MessageID))
+	if payload.PostID != 0 {
+		where = fmt.Sprintf(`<a href="%s">объявлении #%d</a>`,
+			postLink(payload.ChatID, payload.ThreadID, payload.Photos[0].MessageID), payload.PostID)
+	}
+
+	var lines []string
+	for _, s := range similar {
+		earlier := fmt.Sprintf(`<a href="%s">сообщение</a>`, postLink(s.ChatID, s.ThreadID, s.MessageID))
+		if s.PostID != nil {
+			earlier = fmt.Sprintf(`<a href="%s">объявление #%d</a>`, postLink(s.ChatID, s.ThreadID, s.MessageID), *s.PostID)
+		}
+		lines = append(lines, fmt.Sprintf("• %s от user %d, %s (отличие: %d бит)",
+			earlier, s.UserID, s.CreatedAt.Format("02.01.2006"), s.Distance))
+	}
+
+	log.Printf("Фото user=%d в chat=%d похожи на фото других пользователей: %d", payload.UserID, payload.ChatID, len(similar))
+	tglog.Send("🖼 Фото в %s от user %d уже выкладывали другие пользователи:\n%s",
+		where, payload.UserID, strings.Join(lines, "\n"))
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"go_payment_bot/moderation"
+
+	"github.com/go-telegram/bot/models"
+)
+
+const (
+	// Сколько хранится результат распознавания QR-кода на фото
+	qrCacheTTL = 24 * time.Hour
+	// Сколько фото помнит кэш: при волне спама с фото вытесняются самые старые
+	qrCacheSize = 10000
+)
+
+// qrCache — содержимое QR-кодов по FileUniqueID фото: одно и то же фото
+// часто рассылают много раз, скачивать и распознавать его заново незачем.
+// Хранится само содержимое, а не сигналы: белый список может измениться.
+type qrCache struct {
+	mu      sync.Mutex
+	entries map[string]qrEntry
+	order   []string // ключи в порядке добавления, самые старые — в начале
+}
+
+type qrEntry struct {
+	content   string // "" — кода на фото нет
+	checkedAt time.Time
+}
+
+// qrSignals распознаёт QR-код на фото сообщения, если проверка включена
+// в группе, и проверяет его содержимое как текст сообщения
+func (h *Handler) qrSignals(ctx context.Context, msg *models.Message) []moderation.Signal {
+	if len(msg.Photo) == 0 || !h.groupPolicies(ctx, msg.Chat.ID).QRCheck {
+		return nil
+	}
+	photo := largestPhoto(msg.Photo)
+
+	content, ok := h.qr.get(photo.FileUniqueID)
+	if !ok {
+		img, err := h.downloadImage(ctx, photo.FileID)
+		if err != nil {
+			log.Printf("Ошибка скачивания фото для проверки QR-кода: %v", err)
+			return nil
+		}
+		content, err = moderation.DecodeQR(img)
+		if err != nil {
+			log.Printf("Ошибка распознавания QR-кода: %v", err)
+			return nil
+		}
+		h.qr.put(photo.FileUniqueID, content)
+		if content != "" {
+			log.Printf("QR-код на фото от %d в chat=%d: %s", authorID(msg), msg.Chat.ID, content)
+		}
+	}
+	return moderation.AnalyzeQR(content, h.currentAllowlist())
+}
+
+func (c *qrCache) get(fileUniqueID string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[fileUniqueID]
+	if !ok || time.Since(e.checkedAt) > qrCacheTTL {
+		return "", false
+	}
+	return e.content, true
+}
+
+func (c *qrCache) put(fileUniqueID, content string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.entries[fileUniqueID]; !ok {
+		c.order = append(c.order, fileUniqueID)
+	}
+	c.entries[fileUniqueID] = qrEntry{content: content, checkedAt: time.Now()}
+
+	// Вытесняем устаревшие записи и самые старые сверх лимита
+	for len(c.order) > 0 {
+		oldest := c.order[0]
+		if len(c.entries) <= qrCacheSize && time.Since(c.entries[oldest].checkedAt) <= qrCacheTTL {
+			break
+		}
+		delete(c.entries, oldest)
+		c.order = c.order[1:]
+	}
+}
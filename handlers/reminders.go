@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"go_payment_bot/database"
+	"go_payment_bot/jobs"
+	"go_payment_bot/messages"
+	"go_payment_bot/tglog"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+)
+
+// scheduleExpiry ставит (или переносит) удаление поста и напоминание о скором окончании срока
+func (h *Handler) scheduleExpiry(ctx context.Context, postID int, expiresAt time.Time) {
+	key := fmt.Sprintf("expire_post:%d", postID)
+	if err := h.queue.Enqueue(ctx, jobs.TypeExpirePost, key, jobs.ExpirePost{PostID: postID}, expiresAt); err != nil {
+		log.Printf("Ошибка постановки удаления поста %d: %v", postID, err)
+	}
+	h.scheduleExpiryReminder(ctx, postID, expiresAt)
+}
+
+// ScheduleExpiryReminders переставляет напоминания активных постов на
+// EXPIRY_REMINDER_BEFORE до срока: значение могло измениться после
+// публикации. Вызывается при получении лидерства.
+func (h *Handler) ScheduleExpiryReminders(ctx context.Context) {
+	posts, err := h.db.GetPostsAwaitingReminder(ctx)
+	if err != nil {
+		log.Printf("Ошибка получения постов для напоминаний: %v", err)
+		return
+	}
+	for _, p := range posts {
+		h.scheduleExpiryReminder(ctx, p.ID, p.ExpiresAt)
+	}
+}
+
+// scheduleExpiryReminder ставит (или переносит) напоминание о скором окончании срока
+func (h *Handler) scheduleExpiryReminder(ctx context.Context, postID int, expiresAt time.Time) {
+	if h.cfg.ExpiryReminderBefore <= 0 {
+		return
+	}
+	remindAt := expiresAt.Add(-h.cfg.ExpiryReminderBefore)
+	if !remindAt.After(time.Now()) {
+		return
+	}
+	key := fmt.Sprintf("expiry_reminder:%d", postID)
+	if err := h.queue.Enqueue(ctx, jobs.TypeExpiryReminder, key, jobs.ExpiryReminder{PostID: postID}, remindAt); err != nil {
+		log.Printf("Ошибка постановки напоминания для поста %d: %v", postID, err)
+	}
+}
+
+// sendExpiryReminder предупреждает продавца о скором снятии поста (один раз на пост)
+func (h *Handler) sendExpiryReminder(ctx context.Context, job *database.Job, payload jobs.ExpiryReminder) error {
+	p, err := h.db.GetExpiredPost(ctx, payload.PostID)
+	if isNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// Пост продлили — для нового срока поставлено своё напоминание
+	if time.Until(p.ExpiresAt) > h.cfg.ExpiryReminderBefore+time.Minute {
+		return nil
+	}
+
+	topic, err := h.db.GetTopicByID(ctx, p.InternalTopicID)
+	if err != nil {
+		return fmt.Errorf("тема %d: %w", p.InternalTopicID, err)
+	}
+
+	sent, err := h.db.MarkExpiryReminderSent(ctx, p.ID)
+	if err != nil || !sent {
+		return err
+	}
+
+	expiresAt := p.ExpiresAt.In(h.groupLocation(ctx, p.ChatID))
+	_, err = h.bot.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: p.UserID,
+		Text:   messages.FormatExpiryReminder(topic.Title, expiresAt, postLink(p.ChatID, p.TopicID, p.MessageID), topic.Price, topic.DurationDays),
+		ReplyMarkup: &models.InlineKeyboardMarkup{
+			InlineKeyboard: [][]models.InlineKeyboardButton{{
+				{Text: "⏩ Продлить", URL: fmt.Sprintf("https://t.me/%s?start=extend_%d", h.botUsername, p.ID)},
+				{Text: "🔄 Разместить заново", URL: fmt.Sprintf("https://t.me/%s?start=pay_%d", h.botUsername, topic.ID)},
+			}},
+		},
+	})
+	if err != nil {
+		// Пользователь мог заблокировать бота — не повторяем
+		log.Printf("Ошибка отправки напоминания о посте %d: %v", p.ID, err)
+		return nil
+	}
+
+	log.Printf("Напоминание о сроке поста %d отправлено user=%d", p.ID, p.UserID)
+	return nil
+}
+
+// postLink — ссылка на сообщение в супергруппе
+func postLink(chatID int64, threadID, messageID int) string {
+	internalID := strings.TrimPrefix(strconv.FormatInt(chatID, 10), "-100")
+	if threadID != 0 {
+		return fmt.Sprintf("https://t.me/c/%s/%d/%d", internalID, threadID, messageID)
+	}
+	return fmt.Sprintf("https://t.me/c/%s/%d", internalID, messageID)
+}
+
+// startExtend обрабатывает /start extend_<post_id> — счёт на продление поста
+func (h *Handler) startExtend(ctx context.Context, userID int64, postID int) {
+	post, err := h.db.GetPost(ctx, postID)
+	if err != nil || post.UserID != userID || post.IsDeleted {
+		h.send(ctx, userID, messages.MsgExtendUnavailable)
+		return
+	}
+
+	topic, err := h.db.GetTopicByID(ctx, post.TopicID)
+	if err != nil {
+		h.send(ctx, userID, messages.MsgError)
+		return
+	}
+
+	_, err = h.bot.SendInvoice(ctx, &bot.SendInvoiceParams{
+		ChatID:        userID,
+		Title:         "продление объявления",
+		Description:   fmt.Sprintf("Продление на %d дней в теме «%s»", topic.DurationDays, topic.Title),
+		Payload:       fmt.Sprintf("extend_%d_user_%d_%d", post.ID, userID, time.Now().Unix()),
+		ProviderToken: h.cfg.PaymentProviderToken,
+		Currency:      "RUB",
+		Prices: []models.LabeledPrice{{
+			Label:  "продление",
+			Amount: topic.Price,
+		}},
+	})
+	if err != nil {
+		log.Printf("Ошибка отправки инвойса на продление: %v", err)
+	}
+}
+
+// onExtendPaymentSuccess продлевает пост после оплаты.
+// Если пост успели снять — оплата засчитывается как новое размещение.
+func (h *Handler) onExtendPaymentSuccess(ctx context.Context, msg *models.Message) {
+	userID := msg.From.ID
+	p := msg.SuccessfulPayment
+
+	// Формат: extend_<post_id>_user_<user_id>_<ts>
+	parts := strings.Split(p.InvoicePayload, "_")
+	if len(parts) < 2 {
+		log.Printf("Некорректный payload продления: %s", p.InvoicePayload)
+		return
+	}
+	postID, err := strconv.Atoi(parts[1])
+	if err != nil {
+		log.Printf("Некорректный payload продления: %s", p.InvoicePayload)
+		return
+	}
+
+	post, err := h.db.GetPost(ctx, postID)
+	if err != nil {
+		log.Printf("Ошибка: пост %d для продления не найден: %v", postID, err)
+		return
+	}
+	topic, err := h.db.GetTopicByID(ctx, post.TopicID)
+	if err != nil {
+		return
+	}
+
+	_, _ = h.db.CreatePayment(ctx, userID, topic.ID, p.TelegramPaymentChargeID, p.TotalAmount, p.Currency)
+
+	expiresAt, err := h.db.ExtendPost(ctx, post.ID, userID, topic.DurationDays)
+	if isNotFound(err) {
+		_ = h.db.MarkUserPaid(ctx, userID, topic.ID)
+		tglog.Send("💰 Оплата продления %d ₽ от %s (id: %d) — пост %d уже снят, засчитано как новое размещение", p.TotalAmount/100, html.EscapeString(msg.From.FirstName), userID, post.ID)
+		h.send(ctx, userID, messages.MsgExtendUnavailable)
+		h.send(ctx, userID, messages.FormatPaymentSuccess(topic.MaxPhotos))
+		return
+	}
+	if err != nil {
+		log.Printf("Ошибка продления поста %d: %v", post.ID, err)
+		tglog.Send("❌ Оплачено, но не продлено: пост %d, user %d: %v", post.ID, userID, err)
+		h.send(ctx, userID, messages.MsgError)
+		return
+	}
+
+	h.scheduleExpiry(ctx, post.ID, expiresAt)
+
+	tglog.Send("⏩ Продление %d ₽ от %s (id: %d) — тема «%s», пост %d", p.TotalAmount/100, html.EscapeString(msg.From.FirstName), userID, html.EscapeString(topic.Title), post.ID)
+	h.send(ctx, userID, messages.FormatPostExtended(expiresAt.In(h.groupLocation(ctx, topic.GroupID))))
+}
@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"go_payment_bot/database"
+	"go_payment_bot/messages"
+	"go_payment_bot/tglog"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+)
+
+// escalate подбирает ступень эскалации по числу нарушений пользователя в группе
+// и применяет её. Возвращает текст уведомления для группы или пустую строку,
+// если санкции нет (тогда достаточно обычного предупреждения).
+func (h *Handler) escalate(ctx context.Context, msg *models.Message, violationID int) string {
+	steps, err := h.db.GetSanctionSteps(ctx, msg.Chat.ID)
+	if err != nil {
+		log.Printf("Ошибка получения ступеней эскалации: %v", err)
+		return ""
+	}
+
+	// Ступени отсортированы от самой строгой
+	for _, step := range steps {
+		since := time.Now().Add(-time.Duration(step.WindowHours) * time.Hour)
+		count, err := h.db.GetUserGroupViolationsCount(ctx, msg.From.ID, msg.Chat.ID, since)
+		if err != nil {
+			log.Printf("Ошибка подсчёта нарушений user=%d: %v", msg.From.ID, err)
+			return ""
+		}
+		if count < step.Violations {
+			continue
+		}
+
+		reason := fmt.Sprintf("%d-е нарушение за %d ч", count, step.WindowHours)
+		switch step.Action {
+		case database.SanctionMute:
+			return h.muteUser(ctx, msg, step, violationID, reason)
+		case database.SanctionBan:
+			return h.banUser(ctx, msg, violationID, reason)
+		}
+		return ""
+	}
+	return ""
+}
+
+func (h *Handler) muteUser(ctx context.Context, msg *models.Message, step database.SanctionStep, violationID int, reason string) string {
+	var until *time.Time
+	untilDate := 0 // бессрочно
+	if step.DurationMinutes != nil {
+		t := time.Now().Add(time.Duration(*step.DurationMinutes) * time.Minute)
+		until = &t
+		untilDate = int(t.Unix())
+	}
+
+	if err := h.restrictUser(ctx, msg.Chat.ID, msg.From.ID, untilDate); err != nil {
+		log.Printf("Ошибка мьюта user=%d: %v", msg.From.ID, err)
+		tglog.Send("❌ Не удалось замьютить %s (id: %d): %v", html.EscapeString(msg.From.FirstName), msg.From.ID, err)
+		return ""
+	}
+
+	h.saveSanction(ctx, msg, database.SanctionMute, until, violationID, reason)
+
+	untilText := "бессрочно"
+	if until != nil {
+		untilText = "до " + messages.FormatScheduleTime(until.In(h.groupLocation(ctx, msg.Chat.ID)))
+	}
+	log.Printf("Мьют user=%d в chat=%d %s: %s", msg.From.ID, msg.Chat.ID, untilText, reason)
+	tglog.Send("🔇 %s (id: %d) замьючен %s — %s", html.EscapeString(msg.From.FirstName), msg.From.ID, untilText, reason)
+	return messages.FormatMuted(msg.From.ID, msg.From.FirstName, untilText)
+}
+
+// restrictUser запрещает пользователю писать в группе до untilDate (unix, 0 — бессрочно)
+func (h *Handler) restrictUser(ctx context.Context, chatID, userID int64, untilDate int) error {
+	_, err := h.bot.RestrictChatMember(ctx, &bot.RestrictChatMemberParams{
+		ChatID:      chatID,
+		UserID:      userID,
+		Permissions: &models.ChatPermissions{},
+		UntilDate:   untilDate,
+	})
+	return err
+}
+
+func (h *Handler) banUser(ctx context.Context, msg *models.Message, violationID int, reason string) string {
+	_, err := h.bot.BanChatMember(ctx, &bot.BanChatMemberParams{
+		ChatID:         msg.Chat.ID,
+		UserID:         msg.From.ID,
+		RevokeMessages: true,
+	})
+	if err != nil {
+		log.Printf("Ошибка бана user=%d: %v", msg.From.ID, err)
+		tglog.Send("❌ Не удалось забанить %s (id: %d): %v", html.EscapeString(msg.From.FirstName), msg.From.ID, err)
+		return ""
+	}
+
+	// Бан и в боте: платное размещение тоже закрыто
+	_, _ = h.db.GetOrCreateUser(ctx, msg.From.ID, ptrStr(msg.From.Username), ptrStr(msg.From.FirstName), ptrStr(msg.From.LastName))
+	if err := h.db.BanUser(ctx, msg.From.ID, "спам: "+reason); err != nil {
+		log.Printf("Ошибка бана user=%d в БД: %v", msg.From.ID, err)
+	}
+
+	h.saveSanction(ctx, msg, database.SanctionBan, nil, violationID, reason)
+
+	log.Printf("Бан user=%d в chat=%d: %s", msg.From.ID, msg.Chat.ID, reason)
+	tglog.Send("⛔️ %s (id: %d) забанен — %s", html.EscapeString(msg.From.FirstName), msg.From.ID, reason)
+	return messages.FormatBanned(msg.From.ID, msg.From.FirstName)
+}
+
+func (h *Handler) saveSanction(ctx context.Context, msg *models.Message, action database.SanctionAction, until *time.Time, violationID int, reason string) {
+	var vid *int
+	if violationID != 0 {
+		vid = &violationID
+	}
+	_, err := h.db.CreateSanction(ctx, &database.Sanction{
+		UserID:      msg.From.ID,
+		GroupID:     msg.Chat.ID,
+		Action:      action,
+		Until:       until,
+		ViolationID: vid,
+		Reason:      &reason,
+	})
+	if err != nil {
+		log.Printf("Ошибка сохранения санкции user=%d: %v", msg.From.ID, err)
+	}
+}
+
+// liftSanctions снимает действующие санкции пользователя: /unmute <user_id>, /unban <user_id>
+func (h *Handler) liftSanctions(ctx context.Context, admin *models.User, args string, action database.SanctionAction) {
+	userID, err := strconv.ParseInt(strings.TrimSpace(args), 10, 64)
+	if err != nil {
+		h.send(ctx, admin.ID, messages.MsgLiftUsage)
+		return
+	}
+
+	sanctions, err := h.db.GetActiveSanctions(ctx, userID, action)
+	if err != nil {
+		log.Printf("Ошибка получения санкций user=%d: %v", userID, err)
+		h.send(ctx, admin.ID, messages.MsgError)
+		return
+	}
+
+	lifted := 0
+	for _, s := range sanctions {
+		if action == database.SanctionBan {
+			_, err = h.bot.UnbanChatMember(ctx, &bot.UnbanChatMemberParams{
+				ChatID:       s.GroupID,
+				UserID:       userID,
+				OnlyIfBanned: true,
+			})
+		} else {
+			_, err = h.bot.RestrictChatMember(ctx, &bot.RestrictChatMemberParams{
+				ChatID:      s.GroupID,
+				UserID:      userID,
+				Permissions: h.defaultPermissions(ctx, s.GroupID),
+			})
+		}
+		if err != nil {
+			log.Printf("Ошибка снятия санкции %d: %v", s.ID, err)
+			continue
+		}
+		if err := h.db.LiftSanction(ctx, s.ID, admin.ID); err != nil {
+			log.Printf("Ошибка сохранения снятия санкции %d: %v", s.ID, err)
+		}
+		lifted++
+	}
+
+	if action == database.SanctionBan {
+		if err := h.db.UnbanUser(ctx, userID); err != nil {
+			log.Printf("Ошибка разбана user=%d в БД: %v", userID, err)
+		}
+	}
+
+	h.send(ctx, admin.ID, messages.FormatSanctionsLifted(action == database.SanctionBan, userID, lifted, len(sanctions)))
+	tglog.Send("🕊 %s (id: %d) снял %s с пользователя %d (групп: %d)", html.EscapeString(admin.FirstName), admin.ID, action, userID, lifted)
+}
+
+// defaultPermissions — права участников группы по умолчанию, чтобы снять мьют
+// ровно до них, а не выдать больше, чем у остальных
+func (h *Handler) defaultPermissions(ctx context.Context, chatID int64) *models.ChatPermissions {
+	chat, err := h.bot.GetChat(ctx, &bot.GetChatParams{ChatID: chatID})
+	if err == nil && chat.Permissions != nil {
+		return chat.Permissions
+	}
+	return &models.ChatPermissions{
+		CanSendMessages:       true,
+		CanSendAudios:         true,
+		CanSendDocuments:      true,
+		CanSendPhotos:         true,
+		CanSendVideos:         true,
+		CanSendVideoNotes:     true,
+		CanSendVoiceNotes:     true,
+		CanSendPolls:          true,
+		CanSendOtherMessages:  true,
+		CanAddWebPagePreviews: true,
+	}
+}
@@ -0,0 +1,446 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"go_payment_bot/database"
+	"go_payment_bot/jobs"
+	"go_payment_bot/messages"
+	"go_payment_bot/tglog"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+)
+
+// scheduleDays — на сколько дней вперёд можно отложить публикацию
+const scheduleDays = 7
+
+// scheduleHours — часы (по времени группы), доступные для публикации
+var scheduleHours = []int{7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20, 21, 22}
+
+var weekdayShort = [...]string{"Вс", "Пн", "Вт", "Ср", "Чт", "Пт", "Сб"}
+
+// handleScheduleCallback обрабатывает кнопки отложенной публикации.
+// schedID = 0 — объявление из предпросмотра, иначе — ID записи в scheduled_posts.
+//
+// Форматы:
+//
+//	schedule_publish              — выбрать день для объявления из предпросмотра
+//	sched_pick_<id>               — вернуться к выбору дня
+//	sched_resched_<id>            — перенести запланированную публикацию
+//	sched_day_<id>_<YYYYMMDD>     — выбрать время в этот день
+//	sched_at_<id>_<YYYYMMDDhhmm>  — запланировать на это время
+//	sched_cancel_<id>             — отменить публикацию
+//	sched_close                   — закрыть выбор
+func (h *Handler) handleScheduleCallback(ctx context.Context, cb *models.CallbackQuery) {
+	userID := cb.From.ID
+	data := cb.Data
+
+	switch {
+	case data == "schedule_publish":
+		h.showDayPicker(ctx, userID, 0, nil)
+
+	case data == "sched_close":
+		h.deleteCallbackMessage(ctx, cb)
+
+	case strings.HasPrefix(data, "sched_pick_"):
+		if id, err := strconv.Atoi(strings.TrimPrefix(data, "sched_pick_")); err == nil {
+			h.showDayPicker(ctx, userID, id, cb)
+		}
+
+	case strings.HasPrefix(data, "sched_resched_"):
+		if id, err := strconv.Atoi(strings.TrimPrefix(data, "sched_resched_")); err == nil {
+			h.showDayPicker(ctx, userID, id, nil)
+		}
+
+	case strings.HasPrefix(data, "sched_day_"):
+		if id, value, ok := parseScheduleData(strings.TrimPrefix(data, "sched_day_")); ok {
+			h.showHourPicker(ctx, cb, id, value)
+		}
+
+	case strings.HasPrefix(data, "sched_at_"):
+		if id, value, ok := parseScheduleData(strings.TrimPrefix(data, "sched_at_")); ok {
+			h.handleScheduleAt(ctx, cb, id, value)
+		}
+
+	case strings.HasPrefix(data, "sched_cancel_"):
+		if id, err := strconv.Atoi(strings.TrimPrefix(data, "sched_cancel_")); err == nil {
+			h.handleScheduleCancel(ctx, cb, id)
+		}
+	}
+}
+
+// parseScheduleData разбирает "<id>_<value>"
+func parseScheduleData(data string) (int, string, bool) {
+	idStr, value, ok := strings.Cut(data, "_")
+	if !ok {
+		return 0, "", false
+	}
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		return 0, "", false
+	}
+	return id, value, true
+}
+
+// scheduleTopic возвращает тему, в которой будет опубликовано объявление
+func (h *Handler) scheduleTopic(ctx context.Context, userID int64, schedID int) (*database.Topic, bool) {
+	if schedID == 0 {
+		user, err := h.db.GetUser(ctx, userID)
+		if err != nil || user.CurrentTopicID == nil || user.State != database.StateWaitingConfirm {
+			h.send(ctx, userID, "❌ Объявление уже опубликовано или отменено.")
+			return nil, false
+		}
+		topic, err := h.db.GetTopicByID(ctx, *user.CurrentTopicID)
+		if err != nil {
+			h.send(ctx, userID, messages.MsgError)
+			return nil, false
+		}
+		// Модерацию могли включить после показа предпросмотра
+		if topic.ModerationEnabled {
+			h.send(ctx, userID, messages.MsgScheduleModerated)
+			return nil, false
+		}
+		return topic, true
+	}
+
+	sp, err := h.db.GetScheduledPost(ctx, schedID, userID)
+	if err != nil || sp.Status != database.ScheduledPending {
+		h.send(ctx, userID, messages.MsgScheduleNotFound)
+		return nil, false
+	}
+	topic, err := h.db.GetTopicByID(ctx, sp.TopicID)
+	if err != nil {
+		h.send(ctx, userID, messages.MsgError)
+		return nil, false
+	}
+	return topic, true
+}
+
+// groupLocation возвращает часовой пояс группы (или DEFAULT_TIMEZONE)
+func (h *Handler) groupLocation(ctx context.Context, groupID int64) *time.Location {
+	tz := h.cfg.DefaultTimezone
+	if group, err := h.db.GetGroup(ctx, groupID); err == nil && group.Timezone != nil && *group.Timezone != "" {
+		tz = *group.Timezone
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		log.Printf("Неизвестный часовой пояс %q группы %d: %v", tz, groupID, err)
+		return time.Local
+	}
+	return loc
+}
+
+// availableHours возвращает часы дня, которые ещё не прошли
+func availableHours(day, now time.Time) []int {
+	var hours []int
+	for _, hour := range scheduleHours {
+		t := time.Date(day.Year(), day.Month(), day.Day(), hour, 0, 0, 0, day.Location())
+		if t.After(now) {
+			hours = append(hours, hour)
+		}
+	}
+	return hours
+}
+
+// showDayPicker показывает выбор дня. Если cb задан — редактирует его сообщение.
+func (h *Handler) showDayPicker(ctx context.Context, userID int64, schedID int, cb *models.CallbackQuery) {
+	topic, ok := h.scheduleTopic(ctx, userID, schedID)
+	if !ok {
+		return
+	}
+
+	loc := h.groupLocation(ctx, topic.GroupID)
+	now := time.Now().In(loc)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+
+	var rows [][]models.InlineKeyboardButton
+	var row []models.InlineKeyboardButton
+	for i := 0; i < scheduleDays; i++ {
+		day := today.AddDate(0, 0, i)
+		if len(availableHours(day, now)) == 0 {
+			continue
+		}
+
+		label := fmt.Sprintf("%s %s", weekdayShort[day.Weekday()], day.Format("02.01"))
+		switch i {
+		case 0:
+			label = "Сегодня"
+		case 1:
+			label = "Завтра"
+		}
+
+		row = append(row, models.InlineKeyboardButton{
+			Text:         label,
+			CallbackData: fmt.Sprintf("sched_day_%d_%s", schedID, day.Format("20060102")),
+		})
+		if len(row) == 3 {
+			rows = append(rows, row)
+			row = nil
+		}
+	}
+	if len(row) > 0 {
+		rows = append(rows, row)
+	}
+	rows = append(rows, []models.InlineKeyboardButton{{Text: "✖️ Закрыть", CallbackData: "sched_close"}})
+
+	h.sendOrEdit(ctx, userID, cb, messages.FormatScheduleChooseDay(loc.String()), &models.InlineKeyboardMarkup{InlineKeyboard: rows})
+}
+
+// showHourPicker показывает выбор времени в выбранный день
+func (h *Handler) showHourPicker(ctx context.Context, cb *models.CallbackQuery, schedID int, value string) {
+	userID := cb.From.ID
+
+	topic, ok := h.scheduleTopic(ctx, userID, schedID)
+	if !ok {
+		return
+	}
+
+	loc := h.groupLocation(ctx, topic.GroupID)
+	day, err := time.ParseInLocation("20060102", value, loc)
+	if err != nil {
+		return
+	}
+
+	var rows [][]models.InlineKeyboardButton
+	var row []models.InlineKeyboardButton
+	for _, hour := range availableHours(day, time.Now().In(loc)) {
+		t := time.Date(day.Year(), day.Month(), day.Day(), hour, 0, 0, 0, loc)
+		row = append(row, models.InlineKeyboardButton{
+			Text:         t.Format("15:04"),
+			CallbackData: fmt.Sprintf("sched_at_%d_%s", schedID, t.Format("200601021504")),
+		})
+		if len(row) == 4 {
+			rows = append(rows, row)
+			row = nil
+		}
+	}
+	if len(row) > 0 {
+		rows = append(rows, row)
+	}
+	rows = append(rows, []models.InlineKeyboardButton{
+		{Text: "◀️ Назад", CallbackData: fmt.Sprintf("sched_pick_%d", schedID)},
+		{Text: "✖️ Закрыть", CallbackData: "sched_close"},
+	})
+
+	h.sendOrEdit(ctx, userID, cb, messages.FormatScheduleChooseTime(day), &models.InlineKeyboardMarkup{InlineKeyboard: rows})
+}
+
+// handleScheduleAt сохраняет выбранное время публикации
+func (h *Handler) handleScheduleAt(ctx context.Context, cb *models.CallbackQuery, schedID int, value string) {
+	userID := cb.From.ID
+
+	topic, ok := h.scheduleTopic(ctx, userID, schedID)
+	if !ok {
+		return
+	}
+
+	loc := h.groupLocation(ctx, topic.GroupID)
+	publishAt, err := time.ParseInLocation("200601021504", value, loc)
+	if err != nil {
+		return
+	}
+	if !publishAt.After(time.Now()) {
+		h.send(ctx, userID, messages.MsgScheduleInPast)
+		return
+	}
+
+	if schedID == 0 {
+		content := h.getPendingContent(userID)
+		if content == nil {
+			h.send(ctx, userID, "❌ Контент не найден. Отправьте объявление заново.")
+			_ = h.db.UpdateUserState(ctx, userID, database.StateWaitingContent, &topic.ID)
+			return
+		}
+
+		sp, err := h.db.CreateScheduledPost(ctx, userID, topic.ID, &content.Text, content.PhotoIDs, publishAt)
+		if err != nil {
+			log.Printf("Ошибка сохранения отложенной публикации: %v", err)
+			h.send(ctx, userID, messages.MsgError)
+			return
+		}
+		schedID = sp.ID
+
+		if err := h.schedulePublishJob(ctx, schedID, publishAt); err != nil {
+			log.Printf("Ошибка постановки отложенной публикации %d: %v", schedID, err)
+			_, _ = h.db.CancelScheduledPost(ctx, schedID, userID)
+			h.send(ctx, userID, messages.MsgError)
+			return
+		}
+
+		// Контент теперь хранится в БД — предпросмотр больше не нужен
+		h.deleteCallbackMessage(ctx, cb)
+		h.deletePreviewMessages(ctx, userID)
+		h.clearPendingContent(userID)
+		_ = h.db.ResetUser(ctx, userID)
+
+		tglog.Send("🕒 Запланирована публикация от user %d — тема «%s» на %s", userID, html.EscapeString(topic.Title), messages.FormatScheduleTime(publishAt))
+	} else {
+		ok, err := h.db.RescheduleScheduledPost(ctx, schedID, userID, publishAt)
+		if err != nil {
+			log.Printf("Ошибка переноса публикации %d: %v", schedID, err)
+			h.send(ctx, userID, messages.MsgError)
+			return
+		}
+		if !ok {
+			h.send(ctx, userID, messages.MsgScheduleNotFound)
+			return
+		}
+		if err := h.schedulePublishJob(ctx, schedID, publishAt); err != nil {
+			log.Printf("Ошибка переноса задачи публикации %d: %v", schedID, err)
+			h.send(ctx, userID, messages.MsgError)
+			return
+		}
+		h.deleteCallbackMessage(ctx, cb)
+	}
+
+	_, _ = h.bot.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:      userID,
+		Text:        messages.FormatScheduled(topic.Title, publishAt),
+		ReplyMarkup: scheduleManageKeyboard(schedID),
+	})
+}
+
+// handleScheduleCancel отменяет запланированную публикацию
+func (h *Handler) handleScheduleCancel(ctx context.Context, cb *models.CallbackQuery, schedID int) {
+	userID := cb.From.ID
+
+	ok, err := h.db.CancelScheduledPost(ctx, schedID, userID)
+	if err != nil {
+		log.Printf("Ошибка отмены публикации %d: %v", schedID, err)
+		h.send(ctx, userID, messages.MsgError)
+		return
+	}
+	if !ok {
+		h.send(ctx, userID, messages.MsgScheduleNotFound)
+		return
+	}
+
+	tglog.Send("🚫 Отменена отложенная публикация %d от user %d", schedID, userID)
+	h.sendOrEdit(ctx, userID, cb, messages.MsgScheduleCancelled, nil)
+}
+
+// sendScheduledList отправляет пользователю его запланированные публикации
+func (h *Handler) sendScheduledList(ctx context.Context, userID int64) {
+	posts, err := h.db.GetUserScheduledPosts(ctx, userID)
+	if err != nil {
+		log.Printf("Ошибка получения отложенных публикаций: %v", err)
+		h.send(ctx, userID, messages.MsgError)
+		return
+	}
+	if len(posts) == 0 {
+		h.send(ctx, userID, messages.MsgScheduleEmpty)
+		return
+	}
+
+	for _, sp := range posts {
+		topic, err := h.db.GetTopicByID(ctx, sp.TopicID)
+		if err != nil {
+			continue
+		}
+		publishAt := sp.PublishAt.In(h.groupLocation(ctx, topic.GroupID))
+		_, _ = h.bot.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID:      userID,
+			Text:        messages.FormatScheduled(topic.Title, publishAt),
+			ReplyMarkup: scheduleManageKeyboard(sp.ID),
+		})
+	}
+}
+
+func scheduleManageKeyboard(schedID int) *models.InlineKeyboardMarkup {
+	return &models.InlineKeyboardMarkup{
+		InlineKeyboard: [][]models.InlineKeyboardButton{{
+			{Text: "🕒 Перенести", CallbackData: fmt.Sprintf("sched_resched_%d", schedID)},
+			{Text: "❌ Отменить", CallbackData: fmt.Sprintf("sched_cancel_%d", schedID)},
+		}},
+	}
+}
+
+// sendOrEdit редактирует сообщение из callback, а если его нет — отправляет новое
+func (h *Handler) sendOrEdit(ctx context.Context, userID int64, cb *models.CallbackQuery, text string, markup *models.InlineKeyboardMarkup) {
+	if cb != nil && cb.Message.Message != nil {
+		params := &bot.EditMessageTextParams{
+			ChatID:    userID,
+			MessageID: cb.Message.Message.ID,
+			Text:      text,
+		}
+		if markup != nil {
+			params.ReplyMarkup = markup
+		}
+		if _, err := h.bot.EditMessageText(ctx, params); err == nil {
+			return
+		}
+	}
+
+	params := &bot.SendMessageParams{ChatID: userID, Text: text}
+	if markup != nil {
+		params.ReplyMarkup = markup
+	}
+	_, _ = h.bot.SendMessage(ctx, params)
+}
+
+// schedulePublishJob ставит (или переносит) задачу публикации
+func (h *Handler) schedulePublishJob(ctx context.Context, schedID int, publishAt time.Time) error {
+	key := fmt.Sprintf("publish_scheduled:%d", schedID)
+	return h.queue.Enqueue(ctx, jobs.TypePublishScheduled, key, jobs.PublishScheduled{ScheduledPostID: schedID}, publishAt)
+}
+
+// publishScheduledPost публикует отложенный пост, когда наступило его время
+func (h *Handler) publishScheduledPost(ctx context.Context, job *database.Job, payload jobs.PublishScheduled) error {
+	sp, err := h.db.ClaimScheduledPost(ctx, payload.ScheduledPostID)
+	if isNotFound(err) {
+		// Отменён, уже опубликован или перенесён (для нового времени есть своя задача)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	topic, err := h.db.GetTopicByID(ctx, sp.TopicID)
+	if err != nil {
+		_ = h.db.ReleaseScheduledPost(ctx, sp.ID)
+		return h.scheduledPublishFailed(ctx, job, sp, "", err)
+	}
+
+	content := &PendingContent{PhotoIDs: sp.PhotoFileIDs, ReceivedAt: sp.CreatedAt}
+	if sp.ContentText != nil {
+		content.Text = *sp.ContentText
+	}
+
+	post, err := h.publishPost(ctx, sp.UserID, topic, content)
+	if err != nil {
+		_ = h.db.ReleaseScheduledPost(ctx, sp.ID)
+		return h.scheduledPublishFailed(ctx, job, sp, topic.Title, err)
+	}
+
+	var postID *int
+	if post != nil {
+		postID = &post.ID
+	}
+	if err := h.db.MarkScheduledPostPublished(ctx, sp.ID, postID); err != nil {
+		// Пост уже в группе — повтор задачи опубликовал бы его дважды
+		log.Printf("Ошибка сохранения статуса отложенной публикации %d: %v", sp.ID, err)
+	}
+
+	h.send(ctx, sp.UserID, messages.FormatPublished(topic.DurationDays))
+	return nil
+}
+
+// scheduledPublishFailed возвращает ошибку для повтора задачи,
+// а после последней попытки помечает пост как неопубликованный
+func (h *Handler) scheduledPublishFailed(ctx context.Context, job *database.Job, sp *database.ScheduledPost, topicTitle string, err error) error {
+	if !job.IsLastAttempt() {
+		return err
+	}
+
+	_ = h.db.MarkScheduledPostFailed(ctx, sp.ID)
+	tglog.Send("❌ Не удалась отложенная публикация %d от user %d — тема «%s»: %s", sp.ID, sp.UserID, html.EscapeString(topicTitle), html.EscapeString(err.Error()))
+	h.send(ctx, sp.UserID, messages.FormatScheduleFailed(topicTitle))
+	return err
+}
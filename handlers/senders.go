@@ -0,0 +1,316 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"log"
+
+	"go_payment_bot/database"
+	"go_payment_bot/moderation"
+	"go_payment_bot/tglog"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+)
+
+// senderKind — от чьего имени отправлено сообщение в группе
+type senderKind int
+
+const (
+	senderUser senderKind = iota
+	// Анонимный администратор: sender_chat — сама группа
+	senderAnonymousAdmin
+	// Канал, к которому группа привязана как обсуждение
+	senderLinkedChannel
+	// Любой другой канал
+	senderChannel
+)
+
+// classifySender определяет отправителя. Для сообщений от имени чата
+// в from приходит служебный бот, поэтому смотреть нужно на sender_chat.
+func (h *Handler) classifySender(ctx context.Context, msg *models.Message) senderKind {
+	switch {
+	case msg.SenderChat == nil:
+		return senderUser
+	case msg.SenderChat.ID == msg.Chat.ID:
+		return senderAnonymousAdmin
+	case msg.IsAutomaticForward || msg.SenderChat.ID == h.linkedChatID(ctx, msg.Chat.ID):
+		return senderLinkedChannel
+	}
+	return senderChannel
+}
+
+// onGroupMessage применяет политику к отправителю сообщения вне платных тем
+// и при необходимости запускает проверку на спам
+func (h *Handler) onGroupMessage(ctx context.Context, msg *models.Message) {
+	switch h.classifySender(ctx, msg) {
+	case senderAnonymousAdmin, senderLinkedChannel:
+		return
+	case senderChannel:
+		h.onChannelMessage(ctx, msg, false)
+		return
+	}
+
+	if msg.From == nil || msg.From.IsBot {
+		return
+	}
+	if msg.EditDate == 0 && (h.enforceSlowMode(ctx, msg) || h.checkFlood(ctx, msg)) {
+		return
+	}
+	if h.checkNewcomer(ctx, msg) {
+		return
+	}
+	if msg.ViaBot != nil {
+		switch h.groupPolicies(ctx, msg.Chat.ID).ViaBotPolicy {
+		case database.SenderAllow:
+			log.Printf("Сообщение через @%s от user=%d пропущено политикой группы %d", msg.ViaBot.Username, msg.From.ID, msg.Chat.ID)
+			return
+		case database.SenderDelete:
+			if level, reason := h.exemption(ctx, msg); level == exemptFull {
+				log.Printf("Сообщение через @%s от user=%d не удалено (%s)", msg.ViaBot.Username, msg.From.ID, reason)
+				return
+			}
+			h.blockSenderMessage(ctx, msg, "via_bot", "@"+msg.ViaBot.Username)
+			return
+		}
+	}
+
+	h.hashGroupPhoto(ctx, msg)
+	h.moderate(ctx, msg)
+}
+
+// onPaidTopicSender — сообщение в платной теме от имени чата. Анонимные
+// администраторы и привязанный канал пишут свободно, чужой канал оплатить
+// размещение не может, поэтому его сообщение удаляется, если политика
+// группы не разрешает каналы.
+func (h *Handler) onPaidTopicSender(ctx context.Context, msg *models.Message) {
+	if h.classifySender(ctx, msg) == senderChannel {
+		h.onChannelMessage(ctx, msg, true)
+	}
+}
+
+// onChannelMessage — сообщение от имени чужого канала
+func (h *Handler) onChannelMessage(ctx context.Context, msg *models.Message, paidTopic bool) {
+	policy := h.groupPolicies(ctx, msg.Chat.ID).ChannelPolicy
+	switch {
+	case policy == database.SenderAllow:
+		return
+	case policy == database.SenderModerate && !paidTopic:
+		h.moderateChannel(ctx, msg)
+		return
+	}
+	h.blockSenderMessage(ctx, msg, "channel", msg.SenderChat.Title)
+}
+
+// moderateChannel проверяет содержимое сообщения канала. Предупреждать
+// и наказывать некого, поэтому сообщение либо удаляется, либо остаётся.
+func (h *Handler) moderateChannel(ctx context.Context, msg *models.Message) {
+	signals := h.analyzeMessage(ctx, msg)
+	signals = append(signals, h.qrSignals(ctx, msg)...)
+	signals = append(signals, h.attachmentSignals(ctx, msg)...)
+	signals = append(signals, h.currentRules().Apply(msg.Chat.ID, moderation.MessageText(msg))...)
+	signals = append(signals, h.bayesSignals(moderation.MessageText(msg))...)
+	if len(signals) == 0 {
+		return
+	}
+
+	thresholds, shadow := h.spamSettings(ctx, msg.Chat.ID)
+	verdict := moderation.Evaluate(signals, thresholds)
+	if shadow {
+		verdict.Shadow()
+	}
+
+	switch {
+	case verdict.Action == moderation.ActionDelete:
+		h.deleteSpamMessage(ctx, msg)
+		h.saveSpamViolation(ctx, msg, verdict, false)
+		log.Printf("Спам от канала %d: %s%s", msg.SenderChat.ID, verdict.Explain(), editSuffix(msg))
+		tglog.Send("🚫 Спам от канала %s (id: %d)%s\n%s", html.EscapeString(msg.SenderChat.Title), msg.SenderChat.ID,
+			editSuffix(msg), html.EscapeString(verdict.Explain()))
+	case verdict.Action == moderation.ActionWarn:
+		log.Printf("Подозрение на спам от канала %d оставлено: %s%s", msg.SenderChat.ID, verdict.Explain(), editSuffix(msg))
+	case verdict.ShadowAction != moderation.ActionNone:
+		h.handleShadowHit(ctx, msg, verdict)
+	}
+}
+
+// blockSenderMessage удаляет сообщение, запрещённое политикой группы,
+// и записывает нарушение без оценки
+func (h *Handler) blockSenderMessage(ctx context.Context, msg *models.Message, violationType, match string) {
+	h.deleteSpamMessage(ctx, msg)
+
+	text := moderation.MessageText(msg)
+	v := &database.SpamViolation{
+		UserID:        authorID(msg),
+		GroupID:       msg.Chat.ID,
+		MessageText:   &text,
+		ViolationType: violationType,
+		MatchFound:    ptrStr(match),
+		Action:        string(moderation.ActionDelete),
+	}
+	setSenderFields(v, msg)
+	if _, err := h.db.CreateSpamViolation(ctx, v); err != nil {
+		log.Printf("Ошибка сохранения нарушения: %v", err)
+	}
+
+	log.Printf("Удалено сообщение (%s) политикой группы %d: %s", violationType, msg.Chat.ID, senderName(msg))
+	tglog.Send("🚫 Удалено политикой группы: %s (id: %d) — %s%s",
+		html.EscapeString(senderName(msg)), authorID(msg), violationType, editSuffix(msg))
+}
+
+func (h *Handler) deleteSpamMessage(ctx context.Context, msg *models.Message) {
+	_, err := h.bot.DeleteMessage(ctx, &bot.DeleteMessageParams{
+		ChatID:    msg.Chat.ID,
+		MessageID: msg.ID,
+	})
+	if err != nil {
+		log.Printf("Ошибка удаления спам-сообщения: %v", err)
+	}
+}
+
+// groupPolicies — политики группы для отправителей и вложений.
+// Если группы нет в БД — значения по умолчанию из миграций.
+func (h *Handler) groupPolicies(ctx context.Context, chatID int64) *database.Group {
+	group, err := h.db.GetGroup(ctx, chatID)
+	if err != nil {
+		return &database.Group{
+			ChannelPolicy:      database.SenderDelete,
+			ViaBotPolicy:       database.SenderModerate,
+			ContactPolicy:      database.ContentDeny,
+			LocationPolicy:     database.ContentDeny,
+			ForwardPolicy:      database.ContentDeny,
+			StoryPolicy:        database.ContentDeny,
+			FloodAction:        database.FloodMute,
+			RaidAction:         database.FloodMute,
+			NewcomerNoLinks:    true,
+			NewcomerNoCaptions: true,
+			DetectCards:        true,
+			DetectCrypto:       true,
+			DetectEmails:       true,
+		}
+	}
+	return group
+}
+
+// analyzeMessage — сигналы текста, entities и кнопок сообщения без
+// детекторов, выключенных в группе
+func (h *Handler) analyzeMessage(ctx context.Context, msg *models.Message) []moderation.Signal {
+	signals := moderation.AnalyzeMessage(msg, h.currentAllowlist())
+
+	// Настройки группы нужны, только если сработал отключаемый детектор
+	optional := false
+	for _, s := range signals {
+		optional = optional || isOptionalDetector(s.Type)
+	}
+	if !optional {
+		return signals
+	}
+
+	group := h.groupPolicies(ctx, msg.Chat.ID)
+	var kept []moderation.Signal
+	for _, s := range signals {
+		if detectorEnabled(group, s.Type) {
+			kept = append(kept, s)
+		}
+	}
+	// Маскировка без других сигналов — маскировка выключенного детектора
+	if len(kept) == 1 && kept[0].Type == moderation.SignalObfuscated {
+		return nil
+	}
+	return kept
+}
+
+func isOptionalDetector(t moderation.SignalType) bool {
+	switch t {
+	case moderation.SignalCard, moderation.SignalCrypto, moderation.SignalEmail:
+		return true
+	}
+	return false
+}
+
+func detectorEnabled(group *database.Group, t moderation.SignalType) bool {
+	switch t {
+	case moderation.SignalCard:
+		return group.DetectCards
+	case moderation.SignalCrypto:
+		return group.DetectCrypto
+	case moderation.SignalEmail:
+		return group.DetectEmails
+	}
+	return true
+}
+
+// attachmentSignals — сигналы вложений, запрещённых политикой группы.
+// Такие сигналы требуют удаления независимо от оценки.
+func (h *Handler) attachmentSignals(ctx context.Context, msg *models.Message) []moderation.Signal {
+	found := moderation.AnalyzeAttachments(msg, h.currentAllowlist())
+	if len(found) == 0 {
+		return nil
+	}
+
+	group := h.groupPolicies(ctx, msg.Chat.ID)
+	var signals []moderation.Signal
+	for _, s := range found {
+		if attachmentPolicy(group, s.Type) == database.ContentAllow {
+			continue
+		}
+		// Пересылки из привязанного канала — обычное дело в группе обсуждений
+		if s.Type == moderation.SignalForward &&
+			msg.ForwardOrigin.MessageOriginChannel.Chat.ID == h.linkedChatID(ctx, msg.Chat.ID) {
+			continue
+		}
+		s.Action = moderation.ActionDelete
+		signals = append(signals, s)
+	}
+	return signals
+}
+
+func attachmentPolicy(group *database.Group, t moderation.SignalType) database.ContentPolicy {
+	switch t {
+	case moderation.SignalContactCard:
+		return group.ContactPolicy
+	case moderation.SignalLocation:
+		return group.LocationPolicy
+	case moderation.SignalForward:
+		return group.ForwardPolicy
+	case moderation.SignalStory:
+		return group.StoryPolicy
+	}
+	return database.ContentAllow
+}
+
+// authorID — кому приписывается сообщение: каналу, если оно от имени канала
+func authorID(msg *models.Message) int64 {
+	if msg.SenderChat != nil {
+		return msg.SenderChat.ID
+	}
+	return msg.From.ID
+}
+
+// senderName — имя отправителя для логов
+func senderName(msg *models.Message) string {
+	name := ""
+	switch {
+	case msg.SenderChat != nil:
+		name = "канал «" + msg.SenderChat.Title + "»"
+	case msg.From != nil:
+		name = msg.From.FirstName
+	}
+	if msg.ViaBot != nil {
+		name += fmt.Sprintf(" через @%s", msg.ViaBot.Username)
+	}
+	return name
+}
+
+func setSenderFields(v *database.SpamViolation, msg *models.Message) {
+	if msg.MessageThreadID != 0 {
+		v.TopicID = &msg.MessageThreadID
+	}
+	if msg.SenderChat != nil {
+		v.SenderChatID = &msg.SenderChat.ID
+	}
+	if msg.ViaBot != nil {
+		v.ViaBotID = &msg.ViaBot.ID
+	}
+}
@@ -0,0 +1,338 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"html"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"go_payment_bot/database"
+	"go_payment_bot/messages"
+	"go_payment_bot/moderation"
+	"go_payment_bot/tglog"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+)
+
+const (
+	// В течение какого времени одинаковый текст считается повтором
+	repeatedTextWindow = 10 * time.Minute
+	// Короткие сообщения («спасибо», «+») повторяются естественно
+	repeatedTextMinLen = 20
+	// За какой период учитывать прошлые нарушения
+	repeatOffenderWindow = 7 * 24 * time.Hour
+)
+
+// recentText — ключ недавнего сообщения пользователя в группе
+type recentText struct {
+	ChatID int64
+	UserID int64
+	Hash   [sha256.Size]byte
+}
+
+// moderate оценивает сообщение в группе и при превышении порогов
+// предупреждает автора или удаляет сообщение
+func (h *Handler) moderate(ctx context.Context, msg *models.Message) {
+	signals := h.analyzeMessage(ctx, msg)
+	signals = append(signals, h.qrSignals(ctx, msg)...)
+	signals = append(signals, h.attachmentSignals(ctx, msg)...)
+	signals = append(signals, h.currentRules().Apply(msg.Chat.ID, moderation.MessageText(msg))...)
+	signals = append(signals, h.bayesSignals(moderation.MessageText(msg))...)
+	// Повтор текста сам по себе не спам (вопрос задали ещё раз) —
+	// он только усиливает сигналы по содержимому. Текст запоминается всегда.
+	repeated := msg.EditDate == 0 && h.isRepeatedText(msg)
+	if repeated && moderation.HasContent(signals) {
+		signals = append(signals, moderation.NewSignal(moderation.SignalRepeatedText, ""))
+	}
+	if len(signals) == 0 {
+		return
+	}
+
+	// Исключения проверяем только при найденных сигналах — это запросы к API и БД
+	level, reason := h.exemption(ctx, msg)
+	if level == exemptFull {
+		log.Printf("Проверка на спам пропущена для user=%d в chat=%d (%s): %s",
+			msg.From.ID, msg.Chat.ID, reason, moderation.Evaluate(signals, moderation.Thresholds{}).Explain())
+		return
+	}
+
+	// Сведения об авторе сами по себе не повод для санкций — добавляем их
+	// только к сигналам по содержимому (не к упоминанию), заодно экономя запросы к БД
+	if moderation.HasContent(signals) {
+		signals = append(signals, h.authorSignals(ctx, msg.From.ID)...)
+	}
+
+	thresholds, shadow := h.spamSettings(ctx, msg.Chat.ID)
+	verdict := moderation.Evaluate(signals, thresholds)
+	if shadow {
+		verdict.Shadow()
+	}
+	if level == exemptSoft && verdict.Cap(moderation.ActionWarn) {
+		log.Printf("Удаление заменено предупреждением для user=%d в chat=%d (%s)", msg.From.ID, msg.Chat.ID, reason)
+	}
+
+	switch {
+	case verdict.Action == moderation.ActionDelete:
+		h.handleSpamViolation(ctx, msg, verdict)
+	case verdict.Action == moderation.ActionWarn:
+		h.handleSpamWarning(ctx, msg, verdict)
+	case verdict.ShadowAction != moderation.ActionNone:
+		h.handleShadowHit(ctx, msg, verdict)
+	}
+}
+
+// authorSignals — сигналы по автору: незнаком боту, недавно нарушал
+func (h *Handler) authorSignals(ctx context.Context, userID int64) []moderation.Signal {
+	var signals []moderation.Signal
+
+	if _, err := h.db.GetUser(ctx, userID); isNotFound(err) {
+		signals = append(signals, moderation.NewSignal(moderation.SignalNewUser, ""))
+	}
+
+	count, err := h.db.GetUserViolationsCount(ctx, userID, time.Now().Add(-repeatOffenderWindow))
+	if err != nil {
+		log.Printf("Ошибка подсчёта нарушений user=%d: %v", userID, err)
+	} else if count > 0 {
+		signals = append(signals, moderation.NewSignal(moderation.SignalRepeatOffender, ""))
+	}
+
+	return signals
+}
+
+// spamSettings — пороги группы (или значения по умолчанию из конфигурации)
+// и включён ли для неё теневой режим
+func (h *Handler) spamSettings(ctx context.Context, chatID int64) (moderation.Thresholds, bool) {
+	th := moderation.Thresholds{
+		Warn:   h.cfg.SpamWarnThreshold,
+		Delete: h.cfg.SpamDeleteThreshold,
+	}
+	group, err := h.db.GetGroup(ctx, chatID)
+	if err != nil {
+		return th, false
+	}
+	if group.SpamWarnThreshold != nil {
+		th.Warn = *group.SpamWarnThreshold
+	}
+	if group.SpamDeleteThreshold != nil {
+		th.Delete = *group.SpamDeleteThreshold
+	}
+	return th, group.SpamShadow
+}
+
+// isRepeatedText запоминает текст сообщения и сообщает, присылал ли
+// пользователь тот же текст в эту группу недавно (например, в другие топики)
+func (h *Handler) isRepeatedText(msg *models.Message) bool {
+	text := strings.Join(strings.Fields(strings.ToLower(moderation.MessageText(msg))), " ")
+	if len([]rune(text)) < repeatedTextMinLen {
+		return false
+	}
+
+	key := recentText{ChatID: msg.Chat.ID, UserID: msg.From.ID, Hash: sha256.Sum256([]byte(text))}
+	now := time.Now()
+
+	h.recentMu.Lock()
+	defer h.recentMu.Unlock()
+
+	// Чистим устаревшие записи, чтобы карта не росла бесконечно
+	if len(h.recentTexts) > 1000 {
+		for k, t := range h.recentTexts {
+			if now.Sub(t) > repeatedTextWindow {
+				delete(h.recentTexts, k)
+			}
+		}
+	}
+
+	seen, ok := h.recentTexts[key]
+	h.recentTexts[key] = now
+	return ok && now.Sub(seen) <= repeatedTextWindow
+}
+
+func (h *Handler) handleSpamViolation(ctx context.Context, msg *models.Message, verdict moderation.Verdict) {
+	h.deleteSpamMessage(ctx, msg)
+	id := h.saveSpamViolation(ctx, msg, verdict, false)
+
+	// Повторные нарушения — мьют или бан вместо обычного предупреждения
+	notice := h.escalate(ctx, msg, id)
+	if notice == "" {
+		notice = messages.FormatSpamWarning(msg.From.ID, msg.From.FirstName)
+	}
+	h.sendSpamWarning(ctx, msg, notice)
+	h.offerAppeal(ctx, msg, id)
+
+	log.Printf("Спам от user=%d: %s%s", msg.From.ID, verdict.Explain(), editSuffix(msg))
+	tglog.Send("🚫 Спам от %s (id: %d)%s\n%s", html.EscapeString(msg.From.FirstName), msg.From.ID, editSuffix(msg), html.EscapeString(verdict.Explain()))
+}
+
+// handleSpamWarning — оценка между порогами: сообщение остаётся, автор получает предупреждение
+func (h *Handler) handleSpamWarning(ctx context.Context, msg *models.Message, verdict moderation.Verdict) {
+	h.saveSpamViolation(ctx, msg, verdict, false)
+	h.sendSpamWarning(ctx, msg, messages.FormatSpamCaution(msg.From.ID, msg.From.FirstName))
+
+	log.Printf("Подозрение на спам от user=%d: %s%s", msg.From.ID, verdict.Explain(), editSuffix(msg))
+	tglog.Send("⚠️ Подозрение на спам от %s (id: %d)%s\n%s", html.EscapeString(msg.From.FirstName), msg.From.ID, editSuffix(msg), html.EscapeString(verdict.Explain()))
+}
+
+// handleShadowHit — теневой режим группы или теневое правило: сообщение не трогаем,
+// а срабатывание записываем и отправляем модераторам на проверку
+func (h *Handler) handleShadowHit(ctx context.Context, msg *models.Message, verdict moderation.Verdict) {
+	id := h.saveSpamViolation(ctx, msg, verdict, true)
+
+	marker := "👻 Удалил бы"
+	if verdict.ShadowAction == moderation.ActionWarn {
+		marker = "👻 Предупредил бы"
+	}
+	log.Printf("Теневое срабатывание (%s) от %d: %s%s", verdict.ShadowAction, authorID(msg), verdict.Explain(), editSuffix(msg))
+
+	text := fmt.Sprintf("%s: %s (id: %d)%s\n%s\n\n%s", marker, html.EscapeString(senderName(msg)), authorID(msg), editSuffix(msg),
+		html.EscapeString(verdict.Explain()), html.EscapeString(moderation.MessageText(msg)))
+	if id == 0 {
+		tglog.Send("%s", text)
+		return
+	}
+	tglog.SendWithMarkup(&models.InlineKeyboardMarkup{
+		InlineKeyboard: [][]models.InlineKeyboardButton{{
+			{Text: "✅ Спам", CallbackData: fmt.Sprintf("shadow_%s_%d", database.ModVerdictSpam, id)},
+			{Text: "❌ Не спам", CallbackData: fmt.Sprintf("shadow_%s_%d", database.ModVerdictHam, id)},
+		}},
+	}, "%s", text)
+}
+
+// handleShadowVerdict сохраняет решение модератора по теневому срабатыванию.
+// Формат: shadow_<spam|ham>_<violation_id>
+func (h *Handler) handleShadowVerdict(ctx context.Context, cb *models.CallbackQuery) {
+	if !h.isAdmin(cb.From.ID) {
+		return
+	}
+	parts := strings.Split(cb.Data, "_")
+	if len(parts) != 3 {
+		return
+	}
+	verdict := parts[1]
+	id, err := strconv.Atoi(parts[2])
+	if err != nil || (verdict != database.ModVerdictSpam && verdict != database.ModVerdictHam) {
+		return
+	}
+
+	if _, err := h.db.SetSpamViolationVerdict(ctx, id, verdict, cb.From.ID); err != nil {
+		log.Printf("Ошибка сохранения вердикта по нарушению %d: %v", id, err)
+		return
+	}
+	h.learnBayes(ctx, id, verdict)
+
+	msg := cb.Message.Message
+	if msg == nil {
+		return
+	}
+	result := "✅ Спам"
+	if verdict == database.ModVerdictHam {
+		result = "❌ Не спам"
+	}
+	h.markResolved(ctx, msg, result, cb.From.FirstName)
+}
+
+// saveSpamViolation сохраняет нарушение вместе с полным объяснением оценки.
+// Возвращает id нарушения или 0, если сохранить не удалось.
+func (h *Handler) saveSpamViolation(ctx context.Context, msg *models.Message, verdict moderation.Verdict, shadow bool) int {
+	text := moderation.MessageText(msg)
+	signals, err := json.Marshal(verdict.Signals)
+	if err != nil {
+		log.Printf("Ошибка сериализации сигналов: %v", err)
+	}
+
+	action := verdict.Action
+	if shadow {
+		action = verdict.ShadowAction
+	}
+
+	top := verdict.Top()
+	v := &database.SpamViolation{
+		UserID:        authorID(msg),
+		GroupID:       msg.Chat.ID,
+		MessageText:   &text,
+		ViolationType: string(top.Type),
+		MatchFound:    ptrStr(top.Match),
+		EntityType:    ptrStr(top.Entity),
+		Score:         verdict.Score,
+		Action:        string(action),
+		Signals:       signals,
+		IsShadow:      shadow,
+	}
+	setSenderFields(v, msg)
+	id, err := h.db.CreateSpamViolation(ctx, v)
+	if err != nil {
+		log.Printf("Ошибка сохранения нарушения: %v", err)
+		return 0
+	}
+	return id
+}
+
+// sendSpamWarning отправляет предупреждение в топик и удаляет его через 30 сек
+func (h *Handler) sendSpamWarning(ctx context.Context, msg *models.Message, text string) {
+	warning, err := h.bot.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:          msg.Chat.ID,
+		MessageThreadID: msg.MessageThreadID,
+		Text:            text,
+		ParseMode:       models.ParseModeHTML,
+	})
+	if err != nil {
+		log.Printf("Ошибка отправки предупреждения: %v", err)
+		return
+	}
+	h.deleteMessageLater(ctx, msg.Chat.ID, warning.ID, 30*time.Second)
+}
+
+func editSuffix(msg *models.Message) string {
+	if msg.EditDate != 0 {
+		return " (правка)"
+	}
+	return ""
+}
+
+// LoadSpamRules загружает и компилирует включённые правила детектора
+func (h *Handler) LoadSpamRules(ctx context.Context) {
+	rules, err := h.db.GetSpamRules(ctx, true)
+	if err != nil {
+		log.Printf("Ошибка загрузки правил спама: %v", err)
+		return
+	}
+	compiled := make([]*moderation.Rule, len(rules))
+	for i := range rules {
+		compiled[i] = toModerationRule(&rules[i])
+	}
+	ruleSet := moderation.NewRuleSet(compiled)
+	h.moderationMu.Lock()
+	h.rules = ruleSet
+	h.moderationMu.Unlock()
+	log.Printf("Загружено %d правил спама", len(ruleSet))
+}
+
+// currentRules — правила спама, загруженные последними
+func (h *Handler) currentRules() moderation.RuleSet {
+	h.moderationMu.RLock()
+	defer h.moderationMu.RUnlock()
+	return h.rules
+}
+
+func toModerationRule(r *database.SpamRule) *moderation.Rule {
+	rule := &moderation.Rule{
+		ID:      r.ID,
+		Name:    r.Name,
+		Kind:    moderation.RuleKind(r.Kind),
+		Pattern: r.Pattern,
+		Weight:  r.Weight,
+		Shadow:  r.IsShadow,
+	}
+	if r.GroupID != nil {
+		rule.GroupID = *r.GroupID
+	}
+	if r.Action != nil {
+		rule.Action = moderation.Action(*r.Action)
+	}
+	return rule
+}
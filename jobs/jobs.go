@@ -0,0 +1,236 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"go_payment_bot/database"
+	"go_payment_bot/tglog"
+)
+
+type Type string
+
+const (
+	TypeDeleteMessage    Type = "delete_message"
+	TypeExpirePost       Type = "expire_post"
+	TypeExpiredReminder  Type = "expired_reminder"
+	TypeExpiryReminder   Type = "expiry_reminder"
+	TypePublishScheduled Type = "publish_scheduled"
+	TypeCleanupJobs      Type = "cleanup_jobs"
+	TypeReloadModeration Type = "reload_moderation"
+	TypeCaptchaTimeout   Type = "captcha_timeout"
+	TypePhotoHash        Type = "photo_hash"
+)
+
+// DeleteMessage — удалить сообщение (предупреждения и т.п.)
+type DeleteMessage struct {
+	ChatID    int64 `json:"chat_id"`
+	MessageID int   `json:"message_id"`
+}
+
+// ExpirePost — снять пост с публикации по истечении срока
+type ExpirePost struct {
+	PostID int `json:"post_id"`
+}
+
+// ExpiredReminder — напомнить продавцу о переопубликации
+type ExpiredReminder struct {
+	UserID  int64 `json:"user_id"`
+	TopicID int   `json:"topic_id"`
+}
+
+// ExpiryReminder — предупредить продавца о скором окончании срока
+type ExpiryReminder struct {
+	PostID int `json:"post_id"`
+}
+
+// PublishScheduled — опубликовать отложенный пост
+type PublishScheduled struct {
+	ScheduledPostID int `json:"scheduled_post_id"`
+}
+
+// CaptchaTimeout — удалить участника, не прошедшего капчу вовремя
+type CaptchaTimeout struct {
+	VerificationID int `json:"verification_id"`
+}
+
+// PhotoHash — посчитать хэши фото из сообщения или объявления
+// и сообщить модераторам, если такие фото уже выкладывали другие
+type PhotoHash struct {
+	UserID   int64      `json:"user_id"`
+	ChatID   int64      `json:"chat_id"`
+	ThreadID int        `json:"thread_id"`
+	PostID   int        `json:"post_id,omitempty"` // 0 — сообщение в обычной теме
+	Photos   []PhotoRef `json:"photos"`
+}
+
+// PhotoRef — фото и сообщение, в котором оно опубликовано
+type PhotoRef struct {
+	FileID    string `json:"file_id"`
+	MessageID int    `json:"message_id"`
+}
+
+// Empty — задачи без параметров
+type Empty struct{}
+
+const (
+	pollInterval       = 2 * time.Second
+	batchSize          = 20
+	defaultMaxAttempts = 5
+	baseBackoff        = 30 * time.Second
+	maxBackoff         = time.Hour
+	// Задача в running дольше этого срока считается брошенной упавшим процессом
+	staleAfter = 10 * time.Minute
+)
+
+// HandlerFunc выполняет задачу. Ошибка — повтор с backoff,
+// пока не исчерпаны попытки.
+type HandlerFunc func(ctx context.Context, job *database.Job) error
+
+// Handle оборачивает обработчик с типизированным payload
+func Handle[T any](fn func(ctx context.Context, job *database.Job, payload T) error) HandlerFunc {
+	return func(ctx context.Context, job *database.Job) error {
+		var payload T
+		if err := json.Unmarshal(job.Payload, &payload); err != nil {
+			return fmt.Errorf("некорректный payload: %w", err)
+		}
+		return fn(ctx, job, payload)
+	}
+}
+
+// Queue — очередь задач в PostgreSQL. Задачи забираются через
+// FOR UPDATE SKIP LOCKED и переживают перезапуск процесса.
+type Queue struct {
+	db       *database.DB
+	handlers map[Type]HandlerFunc
+}
+
+func New(db *database.DB) *Queue {
+	return &Queue{
+		db:       db,
+		handlers: make(map[Type]HandlerFunc),
+	}
+}
+
+// Register регистрирует обработчик типа задач. Вызывать до Run.
+func (q *Queue) Register(t Type, fn HandlerFunc) {
+	q.handlers[t] = fn
+}
+
+// Enqueue ставит задачу на время runAt. Непустой key делает задачу
+// уникальной: повторный Enqueue с тем же ключом переносит ожидающую задачу.
+func (q *Queue) Enqueue(ctx context.Context, t Type, key string, payload any, runAt time.Time) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	var uniqueKey *string
+	if key != "" {
+		uniqueKey = &key
+	}
+	return q.db.EnqueueJob(ctx, string(t), uniqueKey, data, runAt, defaultMaxAttempts)
+}
+
+// EnqueueOnce ставит задачу с ключом key, если такой ещё нет в ожидании.
+// Для периодических задач: новый лидер не должен откладывать уже
+// запланированный запуск.
+func (q *Queue) EnqueueOnce(ctx context.Context, t Type, key string, payload any, runAt time.Time) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return q.db.EnqueueJobOnce(ctx, string(t), key, data, runAt, defaultMaxAttempts)
+}
+
+// Run разбирает очередь до отмены ctx
+func (q *Queue) Run(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		q.poll(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (q *Queue) poll(ctx context.Context) {
+	for {
+		jobs, err := q.db.ClaimJobs(ctx, batchSize, staleAfter)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("Ошибка получения задач: %v", err)
+			}
+			return
+		}
+		for i := range jobs {
+			q.run(ctx, &jobs[i])
+		}
+		if len(jobs) < batchSize || ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+func (q *Queue) run(ctx context.Context, job *database.Job) {
+	err := q.call(ctx, job)
+
+	// Статус задачи сохраняем даже при остановке бота
+	ctx = context.WithoutCancel(ctx)
+
+	if err == nil {
+		if err := q.db.CompleteJob(ctx, job.ID); err != nil {
+			log.Printf("Ошибка завершения задачи %d: %v", job.ID, err)
+		}
+		return
+	}
+
+	if job.IsLastAttempt() {
+		log.Printf("Задача %s #%d не выполнена (попыток: %d): %v", job.Type, job.ID, job.Attempts, err)
+		tglog.Send("⚠️ Задача %s #%d не выполнена после %d попыток: %v", job.Type, job.ID, job.Attempts, err)
+		if err := q.db.FailJob(ctx, job.ID, err.Error()); err != nil {
+			log.Printf("Ошибка сохранения задачи %d: %v", job.ID, err)
+		}
+		return
+	}
+
+	delay := Backoff(job.Attempts)
+	log.Printf("Задача %s #%d: ошибка (попытка %d/%d), повтор через %s: %v", job.Type, job.ID, job.Attempts, job.MaxAttempts, delay, err)
+	retried, retryErr := q.db.RetryJob(ctx, job.ID, time.Now().Add(delay), err.Error())
+	if retryErr != nil {
+		log.Printf("Ошибка сохранения задачи %d: %v", job.ID, retryErr)
+	} else if !retried {
+		log.Printf("Задача %s #%d не повторяется: поставлена новая с тем же ключом", job.Type, job.ID)
+	}
+}
+
+// call выполняет обработчик, превращая панику в ошибку
+func (q *Queue) call(ctx context.Context, job *database.Job) (err error) {
+	handler, ok := q.handlers[Type(job.Type)]
+	if !ok {
+		// Повторять бессмысленно
+		job.Attempts = job.MaxAttempts
+		return fmt.Errorf("неизвестный тип задачи %q", job.Type)
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return handler(ctx, job)
+}
+
+// Backoff — задержка перед повтором: 30s, 1m, 2m, 4m ... но не больше часа
+func Backoff(attempt int) time.Duration {
+	d := baseBackoff
+	for i := 1; i < attempt && d < maxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, maxBackoff)
+}
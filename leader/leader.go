@@ -0,0 +1,110 @@
+package leader
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"go_payment_bot/database"
+	"go_payment_bot/tglog"
+)
+
+// LockBackgroundWorkers — ключ advisory lock для фоновых воркеров
+const LockBackgroundWorkers int64 = 0x676F5F626F74 // "go_bot"
+
+const (
+	retryInterval     = 15 * time.Second
+	heartbeatInterval = 10 * time.Second
+)
+
+// Elector выбирает лидера через pg_try_advisory_lock. Блокировка держится
+// на отдельном соединении: если процесс или соединение умирают, PostgreSQL
+// снимает её сам, и лидерство переходит к другому инстансу.
+type Elector struct {
+	db         *database.DB
+	lockID     int64
+	instanceID string
+}
+
+func New(db *database.DB, lockID int64, instanceID string) *Elector {
+	return &Elector{
+		db:         db,
+		lockID:     lockID,
+		instanceID: instanceID,
+	}
+}
+
+// Run пытается стать лидером и, пока лидерство удерживается, выполняет fn.
+// ctx, переданный в fn, отменяется при потере лидерства. Возвращается после отмены ctx.
+func (e *Elector) Run(ctx context.Context, fn func(ctx context.Context)) {
+	for {
+		if e.tryLead(ctx, fn) {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(retryInterval):
+		}
+	}
+}
+
+// tryLead возвращает true, если инстанс был лидером (можно сразу пробовать снова)
+func (e *Elector) tryLead(ctx context.Context, fn func(ctx context.Context)) bool {
+	conn, err := e.db.Pool.Acquire(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("Выбор лидера: нет соединения с БД: %v", err)
+		}
+		return false
+	}
+
+	var locked bool
+	if err := conn.QueryRow(ctx, `SELECT pg_try_advisory_lock($1)`, e.lockID).Scan(&locked); err != nil || !locked {
+		if err != nil && ctx.Err() == nil {
+			log.Printf("Выбор лидера: %v", err)
+		}
+		conn.Release()
+		return false
+	}
+
+	// Закрываем соединение целиком — это гарантированно снимает блокировку,
+	// даже если оно в неизвестном состоянии
+	pgConn := conn.Hijack()
+	defer pgConn.Close(context.Background())
+
+	log.Printf("Инстанс %s стал лидером", e.instanceID)
+	tglog.Send("👑 Инстанс <code>%s</code> стал лидером фоновых задач", e.instanceID)
+
+	leaderCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn(leaderCtx)
+	}()
+
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			<-done
+			return false
+		case <-done:
+			return ctx.Err() == nil
+		case <-ticker.C:
+			if err := pgConn.Ping(ctx); err != nil {
+				if ctx.Err() != nil {
+					continue
+				}
+				log.Printf("Инстанс %s потерял лидерство: %v", e.instanceID, err)
+				tglog.Send("⚠️ Инстанс <code>%s</code> потерял лидерство: %v", e.instanceID, err)
+				cancel()
+				<-done
+				return true
+			}
+		}
+	}
+}
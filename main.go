@@ -5,11 +5,12 @@ import (
 	"log"
 	"os"
 	"os/signal"
-	"time"
 
 	"go_payment_bot/config"
 	"go_payment_bot/database"
 	"go_payment_bot/handlers"
+	"go_payment_bot/jobs"
+	"go_payment_bot/leader"
 	"go_payment_bot/tglog"
 
 	"github.com/go-telegram/bot"
@@ -38,10 +39,18 @@ func main() {
 		log.Fatalf("Ошибка подключения к БД: %v", err)
 	}
 	defer db.Close()
-	log.Println("Подключено к БД")
+	log.Printf("Подключено к БД (инстанс %s)", cfg.InstanceID)
 
 	opts := []bot.Option{
 		bot.WithDefaultHandler(func(ctx context.Context, b *bot.Bot, update *models.Update) {}),
+		// chat_member по умолчанию не присылается — он нужен для капчи
+		bot.WithAllowedUpdates(bot.AllowedUpdates{
+			models.AllowedUpdateMessage,
+			models.AllowedUpdateEditedMessage,
+			models.AllowedUpdateCallbackQuery,
+			models.AllowedUpdatePreCheckoutQuery,
+			models.AllowedUpdateChatMember,
+		}),
 	}
 
 	b, err := bot.New(cfg.BotToken, opts...)
@@ -58,24 +67,16 @@ func main() {
 	log.Printf("Бот @%s запущен", botUsername)
 	tglog.Init(b, cfg.LogChannelID)
 
-	h := handlers.New(b, cfg, db, botUsername)
+	queue := jobs.New(db)
+	h := handlers.New(b, cfg, db, queue, botUsername)
+	h.RegisterJobs(queue)
 
-	// Загружаем разрешённые домены
+	// Загружаем разрешённые домены, правила спама и модель классификатора.
+	// Дальше их перечитывает каждый инстанс по сигналу периодической задачи.
 	h.LoadAllowedDomains(ctx)
-
-	// Перезагрузка каждый час (опционально)
-	go func() {
-		ticker := time.NewTicker(1 * time.Hour)
-		defer ticker.Stop()
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			case <-ticker.C:
-				h.LoadAllowedDomains(context.Background())
-			}
-		}
-	}()
+	h.LoadSpamRules(ctx)
+	h.LoadBayes(ctx)
+	go h.ListenReloads(ctx)
 
 	b.RegisterHandler(bot.HandlerTypeMessageText, "", bot.MatchTypePrefix, h.OnMessage)
 	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "", bot.MatchTypePrefix, h.OnCallback)
@@ -88,23 +89,33 @@ func main() {
 		return update.Message != nil && update.Message.SuccessfulPayment != nil
 	}, h.OnMessage)
 
+	// Остальные сообщения в группах (контакты, места, истории, стикеры...) —
+	// тоже на проверку: вложениями передают контакты в обход текста
+	b.RegisterHandlerMatchFunc(func(update *models.Update) bool {
+		return update.Message != nil && update.Message.Chat.Type == "supergroup"
+	}, h.OnMessage)
+
+	b.RegisterHandlerMatchFunc(func(update *models.Update) bool {
+		return update.EditedMessage != nil
+	}, h.OnEditedMessage)
+
 	b.RegisterHandlerMatchFunc(func(update *models.Update) bool {
 		return update.PreCheckoutQuery != nil
 	}, h.OnPreCheckout)
 
-	// Проверка просроченных постов каждые 5 минут
-	go func() {
-		ticker := time.NewTicker(5 * time.Minute)
-		defer ticker.Stop()
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			case <-ticker.C:
-				h.DeleteExpiredPosts(ctx)
-			}
-		}
-	}()
+	b.RegisterHandlerMatchFunc(func(update *models.Update) bool {
+		return update.ChatMember != nil
+	}, h.OnChatMember)
+
+	// Очередь задач (удаление просроченных постов и предупреждений,
+	// напоминания, отложенные публикации) разбирает только лидер.
+	// Обработка апдейтов безопасна на любом инстансе.
+	elector := leader.New(db, leader.LockBackgroundWorkers, cfg.InstanceID)
+	go elector.Run(ctx, func(ctx context.Context) {
+		h.ScheduleRecurringJobs(ctx)
+		h.ScheduleExpiryReminders(ctx)
+		queue.Run(ctx)
+	})
 
 	log.Println("Бот запущен")
 	b.Start(ctx)
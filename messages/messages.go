@@ -1,11 +1,15 @@
 package messages
 
-import "fmt"
+import (
+	"fmt"
+	"html"
+	"time"
+)
 
 const (
 	MsgDeleted = `🚫 Ваше сообщение удалено.
 
-Размещение услуг — платное. Для продолжения нажмите кнопку далее`
+Размещение услуг — платное (%d ₽ за %d дней). Для продолжения нажмите кнопку далее`
 
 	MsgPaymentSuccess = `✅ Оплата прошла!
 
@@ -42,6 +46,76 @@ const (
 	MsgExpiredReminder = `⏰ Срок вашего объявления в теме «%s» истёк и оно удалено.
 
 Хотите разместить заново? 💰 %d ₽ за %d дней.`
+
+	MsgExpiryReminder = `⏳ Ваше объявление в теме «%s» будет снято %s.
+
+🔗 %s
+
+Продлите размещение ещё на %d дней (%d ₽) или разместите новое объявление.`
+
+	MsgPostExtended = `✅ Размещение продлено до %s.`
+
+	MsgExtendUnavailable = `❌ Объявление уже снято с публикации. Разместите новое.`
+
+	MsgScheduleChooseDay = `🗓 Выберите день публикации.
+
+Время указывается по часовому поясу группы (%s).`
+
+	MsgScheduleChooseTime = `🕒 Выберите время публикации на %s.`
+
+	MsgScheduled = `🕒 Объявление в теме «%s» будет опубликовано %s.
+
+Срок размещения отсчитывается с момента публикации.`
+
+	MsgScheduleCancelled = `🚫 Отложенная публикация отменена.`
+
+	MsgScheduleNotFound = `❌ Отложенная публикация не найдена или уже выполнена.`
+
+	MsgScheduleInPast = `❌ Это время уже прошло. Выберите другое.`
+
+	MsgScheduleEmpty = `📭 У вас нет запланированных публикаций.`
+
+	MsgScheduleModerated = `🕒 Отложенная публикация недоступна: объявления в этой теме проходят ручную модерацию.`
+
+	MsgScheduleFailed = `❌ Не удалось опубликовать запланированное объявление в теме «%s». Мы уже разбираемся.`
+
+	MsgTestRuleUsage = `🧪 Проверка правила: /testrule <id> и текст с новой строки.
+
+Пример:
+/testrule 5
+Работа на дому, доход от 5000 в день`
+
+	MsgRuleUsage = `❌ Укажите id правила, например /enablerule 5`
+
+	MsgRuleNotFound = `❌ Правило не найдено.`
+
+	MsgRulesEmpty = `📭 Правил пока нет. Добавьте их в таблицу spam_rules.`
+
+	MsgShadowReportUsage = `❌ Укажите период в днях, например /shadowreport 14`
+
+	MsgLiftUsage = `❌ Укажите id пользователя, например /unmute 123456789`
+
+	MsgRuleToggled = `✅ Правило #%d %s.`
+
+	MsgTrustUsage = `❌ Укажите id пользователя и при необходимости id группы и заметку, например:
+/trust 123456789 -1001234567890 администратор рынка`
+
+	MsgTrustedEmpty = `📭 Доверенных участников нет. Добавьте их командой /trust.`
+
+	MsgSpamRemoved = `🚫 Ваше сообщение в группе «%s» удалено автоматической проверкой на спам.
+
+Текст сообщения:
+%s
+
+Если это ошибка — нажмите «Обжаловать», и модераторы проверят сообщение.`
+
+	MsgAppealSent = `📨 Жалоба отправлена модераторам. Мы сообщим о решении.`
+
+	MsgAppealUnavailable = `❌ Жалоба уже подана или нарушение не найдено.`
+
+	MsgAppealRestored = `✅ Модератор рассмотрел жалобу: ваше сообщение восстановлено в группе.`
+
+	MsgAppealRejected = `❌ Модератор рассмотрел жалобу: сообщение нарушает правила группы и восстановлено не будет.`
 )
 
 func FormatDeleted(price, days int) string {
@@ -68,6 +142,79 @@ func FormatSpamWarning(userID int64, firstName string) string {
 Объявления — только в разделе «Услуги».`, userID, firstName)
 }
 
+func FormatSpamCaution(userID int64, firstName string) string {
+	return fmt.Sprintf(`<a href="tg://user?id=%d">%s</a>, ваше сообщение похоже на рекламу.
+
+⚠️ Публикация номеров телефонов, личных контактов и ссылок запрещена — при повторе сообщение будет удалено.
+
+Объявления — только в разделе «Услуги».`, userID, html.EscapeString(firstName))
+}
+
+func FormatMuted(userID int64, firstName, until string) string {
+	return fmt.Sprintf(`<a href="tg://user?id=%d">%s</a>, ваше сообщение удалено.
+
+🔇 За повторные нарушения вы не можете писать в группу %s.`, userID, html.EscapeString(firstName), until)
+}
+
+func FormatBanned(userID int64, firstName string) string {
+	return fmt.Sprintf(`<a href="tg://user?id=%d">%s</a> заблокирован за повторную рекламу.`, userID, html.EscapeString(firstName))
+}
+
+func FormatSanctionsLifted(ban bool, userID int64, lifted, total int) string {
+	what := "Мьют снят"
+	if ban {
+		what = "Бан снят"
+	}
+	if total == 0 {
+		return fmt.Sprintf("ℹ️ У пользователя %d нет действующих санкций.", userID)
+	}
+	return fmt.Sprintf("✅ %s с пользователя %d в %d из %d групп.", what, userID, lifted, total)
+}
+
+// FormatSpamRemoved — уведомление в личку об удалённом сообщении (HTML)
+func FormatSpamRemoved(groupTitle, text string) string {
+	return fmt.Sprintf(MsgSpamRemoved, html.EscapeString(groupTitle), html.EscapeString(text))
+}
+
+// FormatCaptcha — задание для нового участника (HTML)
+func FormatCaptcha(userID int64, firstName string, a, b, seconds int) string {
+	return fmt.Sprintf(`👋 <a href="tg://user?id=%d">%s</a>, добро пожаловать! Подтвердите, что вы не бот: сколько будет %d + %d?
+
+Ответьте в течение %d сек., иначе вы будете удалены из группы.`, userID, html.EscapeString(firstName), a, b, seconds)
+}
+
+// FormatSlowMode — уведомление участникам о медленном режиме
+func FormatSlowMode(delay time.Duration, until string) string {
+	return fmt.Sprintf("🐢 В группе включён медленный режим: не чаще одного сообщения в %d сек. до %s. Лишние сообщения удаляются.",
+		int(delay.Seconds()), until)
+}
+
+// FormatRestoredMessage — сообщение, восстановленное модератором от имени автора (HTML)
+func FormatRestoredMessage(userID int64, firstName, text string) string {
+	return fmt.Sprintf(`📨 Сообщение от <a href="tg://user?id=%d">%s</a> (восстановлено модератором):
+
+%s`, userID, html.EscapeString(firstName), html.EscapeString(text))
+}
+
+// FormatApprovedMessage — сообщение новичка, одобренное модератором (HTML)
+func FormatApprovedMessage(userID int64, firstName, text string) string {
+	return fmt.Sprintf(`💬 Сообщение от <a href="tg://user?id=%d">%s</a> (одобрено модератором):
+
+%s`, userID, html.EscapeString(firstName), html.EscapeString(text))
+}
+
+func FormatNewcomerRestricted(userID int64, firstName string) string {
+	return fmt.Sprintf(`<a href="tg://user?id=%d">%s</a>, ваше сообщение удалено.
+
+⚠️ Новым участникам группы пока нельзя публиковать ссылки и фото с подписью.`, userID, html.EscapeString(firstName))
+}
+
+func FormatNewcomerHeld(userID int64, firstName string) string {
+	return fmt.Sprintf(`<a href="tg://user?id=%d">%s</a>, сообщения новых участников публикуются после проверки модератором.
+
+⏳ Ваше сообщение отправлено на проверку.`, userID, html.EscapeString(firstName))
+}
+
 func FormatReloadContent(maxPhotos int) string {
 	return fmt.Sprintf(MsgReloadContent, maxPhotos)
 }
@@ -75,3 +222,40 @@ func FormatReloadContent(maxPhotos int) string {
 func FormatExpiredReminder(topicTitle string, price, days int) string {
 	return fmt.Sprintf(MsgExpiredReminder, topicTitle, price/100, days)
 }
+
+// FormatScheduleTime форматирует время публикации для пользователя
+func FormatScheduleTime(t time.Time) string {
+	return t.Format("02.01.2006 в 15:04")
+}
+
+func FormatScheduleChooseDay(tz string) string {
+	return fmt.Sprintf(MsgScheduleChooseDay, tz)
+}
+
+func FormatScheduleChooseTime(day time.Time) string {
+	return fmt.Sprintf(MsgScheduleChooseTime, day.Format("02.01.2006"))
+}
+
+func FormatScheduled(topicTitle string, publishAt time.Time) string {
+	return fmt.Sprintf(MsgScheduled, topicTitle, FormatScheduleTime(publishAt))
+}
+
+func FormatScheduleFailed(topicTitle string) string {
+	return fmt.Sprintf(MsgScheduleFailed, topicTitle)
+}
+
+func FormatExpiryReminder(topicTitle string, expiresAt time.Time, link string, price, days int) string {
+	return fmt.Sprintf(MsgExpiryReminder, topicTitle, FormatScheduleTime(expiresAt), link, days, price/100)
+}
+
+func FormatPostExtended(expiresAt time.Time) string {
+	return fmt.Sprintf(MsgPostExtended, FormatScheduleTime(expiresAt))
+}
+
+func FormatRuleToggled(id int, enabled bool) string {
+	state := "выключено"
+	if enabled {
+		state = "включено"
+	}
+	return fmt.Sprintf(MsgRuleToggled, id, state)
+}
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS scheduled_posts;
+ALTER TABLE groups DROP COLUMN IF EXISTS timezone;
@@ -0,0 +1,17 @@
+ALTER TABLE groups ADD COLUMN IF NOT EXISTS timezone VARCHAR(64);
+
+CREATE TABLE IF NOT EXISTS scheduled_posts (
+   id SERIAL PRIMARY KEY,
+   user_id BIGINT NOT NULL REFERENCES users(id),
+   topic_id INTEGER NOT NULL REFERENCES topics(id) ON DELETE CASCADE,
+   content_text TEXT,
+   photo_file_ids TEXT[],
+   publish_at TIMESTAMPTZ NOT NULL,
+   status VARCHAR(20) NOT NULL DEFAULT 'pending',
+   post_id INTEGER REFERENCES posts(id),
+   created_at TIMESTAMPTZ DEFAULT NOW(),
+   published_at TIMESTAMPTZ
+);
+
+CREATE INDEX idx_scheduled_posts_publish ON scheduled_posts(publish_at) WHERE status = 'pending';
+CREATE INDEX idx_scheduled_posts_user ON scheduled_posts(user_id);
@@ -0,0 +1,30 @@
+CREATE TABLE IF NOT EXISTS jobs (
+   id BIGSERIAL PRIMARY KEY,
+   type VARCHAR(50) NOT NULL,
+   payload JSONB NOT NULL DEFAULT '{}',
+   unique_key VARCHAR(255),
+   run_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+   status VARCHAR(20) NOT NULL DEFAULT 'pending',
+   attempts INTEGER NOT NULL DEFAULT 0,
+   max_attempts INTEGER NOT NULL DEFAULT 5,
+   last_error TEXT,
+   locked_at TIMESTAMPTZ,
+   created_at TIMESTAMPTZ DEFAULT NOW(),
+   finished_at TIMESTAMPTZ
+);
+
+CREATE INDEX idx_jobs_due ON jobs(run_at) WHERE status = 'pending';
+CREATE INDEX idx_jobs_running ON jobs(locked_at) WHERE status = 'running';
+-- Одна ожидающая задача на ключ (перенос = обновление run_at)
+CREATE UNIQUE INDEX idx_jobs_unique_key ON jobs(unique_key) WHERE status = 'pending';
+
+-- Задачи для уже опубликованных и запланированных постов
+INSERT INTO jobs (type, payload, unique_key, run_at)
+SELECT 'expire_post', jsonb_build_object('post_id', id), 'expire_post:' || id, expires_at
+FROM posts
+WHERE is_deleted = FALSE;
+
+INSERT INTO jobs (type, payload, unique_key, run_at)
+SELECT 'publish_scheduled', jsonb_build_object('scheduled_post_id', id), 'publish_scheduled:' || id, publish_at
+FROM scheduled_posts
+WHERE status = 'pending';
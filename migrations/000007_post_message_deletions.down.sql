@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS post_message_deletions;
+ALTER TABLE posts DROP COLUMN IF EXISTS deletion_attempts;
+ALTER TABLE posts DROP COLUMN IF EXISTS deletion_status;
@@ -0,0 +1,17 @@
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS deletion_status VARCHAR(20);
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS deletion_attempts INTEGER NOT NULL DEFAULT 0;
+
+-- Статус удаления каждого сообщения поста:
+-- pending (ждёт/повтор), deleted, gone (уже нет в чате), no_rights
+CREATE TABLE IF NOT EXISTS post_message_deletions (
+   post_id INTEGER NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+   chat_id BIGINT NOT NULL,
+   message_id INTEGER NOT NULL,
+   status VARCHAR(20) NOT NULL DEFAULT 'pending',
+   attempts INTEGER NOT NULL DEFAULT 0,
+   last_error TEXT,
+   updated_at TIMESTAMPTZ DEFAULT NOW(),
+   PRIMARY KEY (post_id, message_id)
+);
+
+UPDATE posts SET deletion_status = 'done' WHERE is_deleted = TRUE;
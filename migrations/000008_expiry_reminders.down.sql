@@ -0,0 +1,2 @@
+DELETE FROM jobs WHERE type = 'expiry_reminder';
+ALTER TABLE posts DROP COLUMN IF EXISTS expiry_reminder_sent_at;
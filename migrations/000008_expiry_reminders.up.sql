@@ -0,0 +1,4 @@
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS expiry_reminder_sent_at TIMESTAMPTZ;
+
+-- Напоминания для уже опубликованных постов ставит лидер при запуске
+-- (ScheduleExpiryReminders) со сдвигом из EXPIRY_REMINDER_BEFORE
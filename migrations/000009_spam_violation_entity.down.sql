@@ -0,0 +1 @@
+ALTER TABLE spam_violations DROP COLUMN IF EXISTS entity_type;
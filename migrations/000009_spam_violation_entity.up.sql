@@ -0,0 +1 @@
+ALTER TABLE spam_violations ADD COLUMN IF NOT EXISTS entity_type VARCHAR(30);
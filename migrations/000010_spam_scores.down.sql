@@ -0,0 +1,6 @@
+ALTER TABLE spam_violations DROP COLUMN IF EXISTS signals;
+ALTER TABLE spam_violations DROP COLUMN IF EXISTS action;
+ALTER TABLE spam_violations DROP COLUMN IF EXISTS score;
+
+ALTER TABLE groups DROP COLUMN IF EXISTS spam_delete_threshold;
+ALTER TABLE groups DROP COLUMN IF EXISTS spam_warn_threshold;
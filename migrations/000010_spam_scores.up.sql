@@ -0,0 +1,8 @@
+-- Пороги оценки спама для группы (NULL — значения из конфигурации)
+ALTER TABLE groups ADD COLUMN IF NOT EXISTS spam_warn_threshold INT;
+ALTER TABLE groups ADD COLUMN IF NOT EXISTS spam_delete_threshold INT;
+
+-- Объяснение решения: оценка, действие и все найденные сигналы с весами
+ALTER TABLE spam_violations ADD COLUMN IF NOT EXISTS score INT;
+ALTER TABLE spam_violations ADD COLUMN IF NOT EXISTS action VARCHAR(20);
+ALTER TABLE spam_violations ADD COLUMN IF NOT EXISTS signals JSONB;
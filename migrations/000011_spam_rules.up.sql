@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS spam_rules (
+   id SERIAL PRIMARY KEY,
+   -- NULL — правило для всех групп
+   group_id BIGINT REFERENCES groups(id) ON DELETE CASCADE,
+   name VARCHAR(100) NOT NULL,
+   -- regex — регулярное выражение, keywords — слова/фразы через запятую или с новой строки
+   kind VARCHAR(20) NOT NULL DEFAULT 'keywords',
+   pattern TEXT NOT NULL,
+   weight INT NOT NULL DEFAULT 0,
+   -- warn / delete — действие при срабатывании независимо от оценки, NULL — только вес
+   action VARCHAR(20),
+   -- Новые правила выключены: сначала проверить через /testrule
+   is_enabled BOOLEAN NOT NULL DEFAULT FALSE,
+   created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX idx_spam_rules_group ON spam_rules(group_id);
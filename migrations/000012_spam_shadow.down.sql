@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS idx_spam_violations_shadow;
+
+ALTER TABLE spam_violations DROP COLUMN IF EXISTS reviewed_at;
+ALTER TABLE spam_violations DROP COLUMN IF EXISTS reviewed_by;
+ALTER TABLE spam_violations DROP COLUMN IF EXISTS mod_verdict;
+ALTER TABLE spam_violations DROP COLUMN IF EXISTS is_shadow;
+
+ALTER TABLE spam_rules DROP COLUMN IF EXISTS is_shadow;
+ALTER TABLE groups DROP COLUMN IF EXISTS spam_shadow;
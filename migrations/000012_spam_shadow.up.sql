@@ -0,0 +1,11 @@
+-- Теневой режим: нарушения записываются и уходят в лог, но сообщения не удаляются
+ALTER TABLE groups ADD COLUMN IF NOT EXISTS spam_shadow BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE spam_rules ADD COLUMN IF NOT EXISTS is_shadow BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Теневые срабатывания и их проверка модераторами (spam / ham)
+ALTER TABLE spam_violations ADD COLUMN IF NOT EXISTS is_shadow BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE spam_violations ADD COLUMN IF NOT EXISTS mod_verdict VARCHAR(10);
+ALTER TABLE spam_violations ADD COLUMN IF NOT EXISTS reviewed_by BIGINT;
+ALTER TABLE spam_violations ADD COLUMN IF NOT EXISTS reviewed_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_spam_violations_shadow ON spam_violations(created_at) WHERE is_shadow;
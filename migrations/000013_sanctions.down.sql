@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_spam_violations_user_group;
+DROP TABLE IF EXISTS sanctions;
+DROP TABLE IF EXISTS sanction_steps;
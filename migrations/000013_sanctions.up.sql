@@ -0,0 +1,36 @@
+-- Ступени эскалации: N-е нарушение за window_hours → действие
+CREATE TABLE IF NOT EXISTS sanction_steps (
+   id SERIAL PRIMARY KEY,
+   -- NULL — ступени по умолчанию для групп без своих
+   group_id BIGINT REFERENCES groups(id) ON DELETE CASCADE,
+   violations INT NOT NULL,
+   window_hours INT NOT NULL DEFAULT 24,
+   -- warn / mute / ban
+   action VARCHAR(10) NOT NULL,
+   -- Для mute: длительность в минутах, NULL — бессрочно
+   duration_minutes INT,
+   UNIQUE NULLS NOT DISTINCT (group_id, violations)
+);
+
+INSERT INTO sanction_steps (group_id, violations, window_hours, action, duration_minutes) VALUES
+  (NULL, 1, 24, 'warn', NULL),
+  (NULL, 3, 24, 'mute', 60),
+  (NULL, 5, 168, 'ban', NULL)
+ON CONFLICT DO NOTHING;
+
+-- Журнал наложенных санкций
+CREATE TABLE IF NOT EXISTS sanctions (
+   id SERIAL PRIMARY KEY,
+   user_id BIGINT NOT NULL,
+   group_id BIGINT NOT NULL,
+   action VARCHAR(10) NOT NULL,
+   until TIMESTAMPTZ,
+   violation_id INT REFERENCES spam_violations(id) ON DELETE SET NULL,
+   reason TEXT,
+   created_at TIMESTAMPTZ DEFAULT NOW(),
+   lifted_at TIMESTAMPTZ,
+   lifted_by BIGINT
+);
+
+CREATE INDEX idx_sanctions_user ON sanctions(user_id, group_id);
+CREATE INDEX idx_spam_violations_user_group ON spam_violations(user_id, group_id, created_at);
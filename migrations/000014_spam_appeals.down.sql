@@ -0,0 +1,4 @@
+ALTER TABLE spam_violations DROP COLUMN IF EXISTS appeal_resolved_at;
+ALTER TABLE spam_violations DROP COLUMN IF EXISTS appeal_resolved_by;
+ALTER TABLE spam_violations DROP COLUMN IF EXISTS appealed_at;
+ALTER TABLE spam_violations DROP COLUMN IF EXISTS appeal_status;
@@ -0,0 +1,5 @@
+-- Апелляции на удалённые сообщения: pending → restored / whitelisted / rejected
+ALTER TABLE spam_violations ADD COLUMN IF NOT EXISTS appeal_status VARCHAR(20);
+ALTER TABLE spam_violations ADD COLUMN IF NOT EXISTS appealed_at TIMESTAMPTZ;
+ALTER TABLE spam_violations ADD COLUMN IF NOT EXISTS appeal_resolved_by BIGINT;
+ALTER TABLE spam_violations ADD COLUMN IF NOT EXISTS appeal_resolved_at TIMESTAMPTZ;
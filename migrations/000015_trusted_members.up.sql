@@ -0,0 +1,11 @@
+-- Доверенные участники: сообщения не проверяются на спам
+CREATE TABLE IF NOT EXISTS trusted_members (
+   id SERIAL PRIMARY KEY,
+   user_id BIGINT NOT NULL,
+   -- NULL — во всех группах
+   group_id BIGINT REFERENCES groups(id) ON DELETE CASCADE,
+   note TEXT,
+   added_by BIGINT,
+   created_at TIMESTAMPTZ DEFAULT NOW(),
+   UNIQUE NULLS NOT DISTINCT (user_id, group_id)
+);
@@ -0,0 +1,4 @@
+ALTER TABLE spam_violations DROP COLUMN IF EXISTS via_bot_id;
+ALTER TABLE spam_violations DROP COLUMN IF EXISTS sender_chat_id;
+ALTER TABLE groups DROP COLUMN IF EXISTS via_bot_policy;
+ALTER TABLE groups DROP COLUMN IF EXISTS channel_policy;
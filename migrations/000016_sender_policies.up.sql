@@ -0,0 +1,8 @@
+-- Политики для сообщений не от имени пользователя:
+-- allow — пропускать, moderate — проверять на спам, delete — удалять
+ALTER TABLE groups ADD COLUMN IF NOT EXISTS channel_policy VARCHAR(10) NOT NULL DEFAULT 'delete';
+ALTER TABLE groups ADD COLUMN IF NOT EXISTS via_bot_policy VARCHAR(10) NOT NULL DEFAULT 'moderate';
+
+-- Кто на самом деле отправил сообщение: канал (user_id тогда — id канала) и inline-бот
+ALTER TABLE spam_violations ADD COLUMN IF NOT EXISTS sender_chat_id BIGINT;
+ALTER TABLE spam_violations ADD COLUMN IF NOT EXISTS via_bot_id BIGINT;
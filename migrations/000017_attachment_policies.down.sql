@@ -0,0 +1,4 @@
+ALTER TABLE groups DROP COLUMN IF EXISTS story_policy;
+ALTER TABLE groups DROP COLUMN IF EXISTS forward_policy;
+ALTER TABLE groups DROP COLUMN IF EXISTS location_policy;
+ALTER TABLE groups DROP COLUMN IF EXISTS contact_policy;
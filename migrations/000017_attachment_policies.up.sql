@@ -0,0 +1,5 @@
+-- Политики для вложений, которыми передают контакт в обход текста: allow / deny
+ALTER TABLE groups ADD COLUMN IF NOT EXISTS contact_policy VARCHAR(10) NOT NULL DEFAULT 'deny';
+ALTER TABLE groups ADD COLUMN IF NOT EXISTS location_policy VARCHAR(10) NOT NULL DEFAULT 'deny';
+ALTER TABLE groups ADD COLUMN IF NOT EXISTS forward_policy VARCHAR(10) NOT NULL DEFAULT 'deny';
+ALTER TABLE groups ADD COLUMN IF NOT EXISTS story_policy VARCHAR(10) NOT NULL DEFAULT 'deny';
@@ -0,0 +1,2 @@
+ALTER TABLE groups DROP COLUMN IF EXISTS raid_action;
+ALTER TABLE groups DROP COLUMN IF EXISTS flood_action;
@@ -0,0 +1,3 @@
+-- Действия при флуде пользователя и рейде: delete / mute / slowmode
+ALTER TABLE groups ADD COLUMN IF NOT EXISTS flood_action VARCHAR(10) NOT NULL DEFAULT 'mute';
+ALTER TABLE groups ADD COLUMN IF NOT EXISTS raid_action VARCHAR(10) NOT NULL DEFAULT 'mute';
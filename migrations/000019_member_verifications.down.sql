@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS member_verifications;
+ALTER TABLE groups DROP COLUMN IF EXISTS captcha_timeout;
+ALTER TABLE groups DROP COLUMN IF EXISTS captcha_enabled;
@@ -0,0 +1,20 @@
+-- Капча для новых участников
+ALTER TABLE groups ADD COLUMN IF NOT EXISTS captcha_enabled BOOLEAN NOT NULL DEFAULT FALSE;
+-- Время на ответ в секундах, NULL — из конфигурации
+ALTER TABLE groups ADD COLUMN IF NOT EXISTS captcha_timeout INT;
+
+-- Проверки участников: прошедших повторно не проверяем
+CREATE TABLE IF NOT EXISTS member_verifications (
+   id SERIAL PRIMARY KEY,
+   user_id BIGINT NOT NULL,
+   group_id BIGINT NOT NULL REFERENCES groups(id) ON DELETE CASCADE,
+   -- pending / passed / failed
+   status VARCHAR(10) NOT NULL DEFAULT 'pending',
+   answer INT NOT NULL,
+   -- Сообщение с заданием
+   message_id INT,
+   expires_at TIMESTAMPTZ NOT NULL,
+   created_at TIMESTAMPTZ DEFAULT NOW(),
+   resolved_at TIMESTAMPTZ,
+   UNIQUE (user_id, group_id)
+);
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS group_members;
+ALTER TABLE groups DROP COLUMN IF EXISTS newcomer_hold;
+ALTER TABLE groups DROP COLUMN IF EXISTS newcomer_no_captions;
+ALTER TABLE groups DROP COLUMN IF EXISTS newcomer_no_links;
+ALTER TABLE groups DROP COLUMN IF EXISTS newcomer_hours;
+ALTER TABLE groups DROP COLUMN IF EXISTS newcomer_messages;
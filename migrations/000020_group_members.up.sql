@@ -0,0 +1,29 @@
+-- Ограничения для новичков: первые newcomer_messages сообщений
+-- или первые newcomer_hours часов в группе (0 — без ограничения)
+ALTER TABLE groups ADD COLUMN IF NOT EXISTS newcomer_messages INT NOT NULL DEFAULT 0;
+ALTER TABLE groups ADD COLUMN IF NOT EXISTS newcomer_hours INT NOT NULL DEFAULT 0;
+-- Запрет любых ссылок, даже из белого списка
+ALTER TABLE groups ADD COLUMN IF NOT EXISTS newcomer_no_links BOOLEAN NOT NULL DEFAULT TRUE;
+-- Запрет фото с подписью
+ALTER TABLE groups ADD COLUMN IF NOT EXISTS newcomer_no_captions BOOLEAN NOT NULL DEFAULT TRUE;
+-- Сообщения публикуются только после одобрения модератором
+ALTER TABLE groups ADD COLUMN IF NOT EXISTS newcomer_hold BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Участники групп: когда впервые замечены и сколько написали
+CREATE TABLE IF NOT EXISTS group_members (
+   user_id BIGINT NOT NULL,
+   group_id BIGINT NOT NULL REFERENCES groups(id) ON DELETE CASCADE,
+   first_seen_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+   message_count INT NOT NULL DEFAULT 0,
+   last_message_at TIMESTAMPTZ,
+   PRIMARY KEY (user_id, group_id)
+);
+
+-- Уже известные участники — по первому нарушению в группе
+INSERT INTO group_members (user_id, group_id, first_seen_at)
+SELECT v.user_id, v.group_id, MIN(v.created_at)
+FROM spam_violations v
+JOIN groups g ON g.id = v.group_id
+WHERE v.created_at IS NOT NULL
+GROUP BY v.user_id, v.group_id
+ON CONFLICT DO NOTHING;
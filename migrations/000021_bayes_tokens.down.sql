@@ -0,0 +1,2 @@
+ALTER TABLE spam_violations DROP COLUMN IF EXISTS bayes_label;
+DROP TABLE IF EXISTS bayes_tokens;
@@ -0,0 +1,10 @@
+-- Модель байесовского классификатора: в скольких спам- и не спам-сообщениях
+-- встретился токен. Пустой токен — счётчики самих сообщений.
+CREATE TABLE IF NOT EXISTS bayes_tokens (
+   token TEXT PRIMARY KEY,
+   spam_count INT NOT NULL DEFAULT 0,
+   ham_count INT NOT NULL DEFAULT 0
+);
+
+-- Каким классом нарушение учтено в модели: spam / ham, NULL — не учтено
+ALTER TABLE spam_violations ADD COLUMN IF NOT EXISTS bayes_label VARCHAR(4);
@@ -0,0 +1,3 @@
+ALTER TABLE groups DROP COLUMN IF EXISTS detect_emails;
+ALTER TABLE groups DROP COLUMN IF EXISTS detect_crypto;
+ALTER TABLE groups DROP COLUMN IF EXISTS detect_cards;
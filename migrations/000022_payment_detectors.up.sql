@@ -0,0 +1,4 @@
+-- Детекторы номеров карт, адресов криптокошельков и email
+ALTER TABLE groups ADD COLUMN IF NOT EXISTS detect_cards BOOLEAN NOT NULL DEFAULT TRUE;
+ALTER TABLE groups ADD COLUMN IF NOT EXISTS detect_crypto BOOLEAN NOT NULL DEFAULT TRUE;
+ALTER TABLE groups ADD COLUMN IF NOT EXISTS detect_emails BOOLEAN NOT NULL DEFAULT TRUE;
@@ -0,0 +1,2 @@
+-- Распознавание QR-кодов на фото вне платных тем
+ALTER TABLE groups ADD COLUMN IF NOT EXISTS qr_check BOOLEAN NOT NULL DEFAULT FALSE;
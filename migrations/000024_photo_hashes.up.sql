@@ -0,0 +1,16 @@
+-- Перцептивные хэши фото из объявлений и обычных тем: по ним ищутся
+-- одни и те же фото, которые выкладывают разные пользователи
+CREATE TABLE IF NOT EXISTS photo_hashes (
+   id SERIAL PRIMARY KEY,
+   file_unique_id VARCHAR(64) NOT NULL,
+   hash BIGINT NOT NULL,
+   user_id BIGINT NOT NULL,
+   chat_id BIGINT NOT NULL,
+   thread_id INT NOT NULL DEFAULT 0,
+   message_id INT NOT NULL,
+   -- Объявление, в котором опубликовано фото; NULL — сообщение в обычной теме
+   post_id INT REFERENCES posts(id) ON DELETE SET NULL,
+   created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_photo_hashes_file ON photo_hashes(file_unique_id);
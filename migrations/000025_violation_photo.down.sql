@@ -0,0 +1 @@
+ALTER TABLE spam_violations DROP COLUMN IF EXISTS photo_file_id;
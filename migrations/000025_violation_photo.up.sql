@@ -0,0 +1,2 @@
+-- Фото задержанного сообщения: публикуется вместе с подписью после одобрения
+ALTER TABLE spam_violations ADD COLUMN IF NOT EXISTS photo_file_id TEXT;
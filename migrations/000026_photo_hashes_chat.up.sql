@@ -0,0 +1,2 @@
+-- Похожие фото ищутся в пределах группы за последние месяцы
+CREATE INDEX IF NOT EXISTS idx_photo_hashes_chat ON photo_hashes(chat_id, created_at);
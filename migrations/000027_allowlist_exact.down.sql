@@ -0,0 +1 @@
+UPDATE allowed_domains SET domain = 'mil.ru' WHERE domain = '=mil.ru';
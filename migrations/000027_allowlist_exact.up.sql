@@ -0,0 +1,4 @@
+-- mil.ru — публичный суффикс: запись на него без "=" разрешала бы любой
+-- поддомен и теперь пропускается. "=mil.ru" разрешает только сам сайт.
+UPDATE allowed_domains SET domain = '=mil.ru'
+WHERE domain = 'mil.ru' AND NOT EXISTS (SELECT 1 FROM allowed_domains WHERE domain = '=mil.ru');
@@ -0,0 +1,135 @@
+package moderation
+
+import (
+	"log"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/idna"
+	"golang.org/x/net/publicsuffix"
+)
+
+// AllowRule — правило белого списка: домен и, при необходимости, префикс пути.
+// "ozon.ru" разрешает ozon.ru и любые его поддомены,
+// "t.me/ozon_official" — только ссылки на этот канал,
+// "=mil.ru" — только сам mil.ru (и www.mil.ru), без других поддоменов.
+type AllowRule struct {
+	Host       string // в ASCII (punycode)
+	PathPrefix string // пустой — любой путь
+	Exact      bool   // без поддоменов
+}
+
+// Allowlist — разобранный белый список ссылок
+type Allowlist []AllowRule
+
+// NewAllowlist разбирает записи allowed_domains. Некорректные записи
+// и публичные суффиксы (com, gov.ru...) пропускаются с предупреждением в лог.
+func NewAllowlist(entries []string) Allowlist {
+	list := make(Allowlist, 0, len(entries))
+	for _, entry := range entries {
+		rule, ok := ParseAllowRule(entry)
+		if !ok {
+			log.Printf("Пропущено правило белого списка %q: некорректная запись или публичный суффикс (для сайта на таком домене — «=домен»)", entry)
+			continue
+		}
+		list = append(list, rule)
+	}
+	return list
+}
+
+// ParseAllowRule разбирает запись вида "[=]домен[/путь]", в том числе
+// кириллические домены ("мвд.рф") и записи со схемой ("https://t.me/channel").
+// Запись с "=" разрешает только сам домен — так можно разрешить сайт,
+// домен которого сам является публичным суффиксом (mil.ru).
+func ParseAllowRule(entry string) (AllowRule, bool) {
+	entry, exact := strings.CutPrefix(strings.TrimSpace(entry), "=")
+	u, ok := parseLink(entry)
+	if !ok {
+		return AllowRule{}, false
+	}
+
+	host := strings.TrimPrefix(u.host, "www.")
+	// Правило на весь публичный суффикс разрешило бы любой сайт в зоне
+	if _, err := publicsuffix.EffectiveTLDPlusOne(host); err != nil && !exact {
+		return AllowRule{}, false
+	}
+
+	return AllowRule{
+		Host:       host,
+		PathPrefix: strings.TrimSuffix(u.path, "/"),
+		Exact:      exact,
+	}, true
+}
+
+// Allows проверяет ссылку: хост совпадает с доменом правила или является
+// его поддоменом, а путь начинается с префикса правила (по сегментам)
+func (l Allowlist) Allows(link string) bool {
+	u, ok := parseLink(link)
+	if !ok {
+		return false
+	}
+	for _, rule := range l {
+		if rule.matches(u) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r AllowRule) matches(u parsedLink) bool {
+	// Правило с "=" разрешает из поддоменов только www
+	subdomain := strings.HasSuffix(u.host, "."+r.Host)
+	if r.Exact {
+		subdomain = u.host == "www."+r.Host
+	}
+	if u.host != r.Host && !subdomain {
+		return false
+	}
+	if r.PathPrefix == "" {
+		return true
+	}
+	return u.path == r.PathPrefix || strings.HasPrefix(u.path, r.PathPrefix+"/")
+}
+
+type parsedLink struct {
+	host string
+	path string
+}
+
+// parseLink выделяет из ссылки хост (в punycode) и путь. Ссылки без схемы
+// считаются http. Параметры запроса и фрагмент не учитываются: в них можно
+// вписать что угодно (evil.com/?r=youtube.com).
+func parseLink(link string) (parsedLink, bool) {
+	link = strings.TrimRight(strings.TrimSpace(link), ".,;:!?)]}»\"'")
+	if link == "" {
+		return parsedLink{}, false
+	}
+	if !strings.Contains(link, "://") {
+		link = "http://" + link
+	}
+
+	u, err := url.Parse(link)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return parsedLink{}, false
+	}
+
+	host := strings.TrimSuffix(u.Hostname(), ".")
+	host, err = idna.Lookup.ToASCII(host)
+	if err != nil || host == "" || !strings.Contains(host, ".") {
+		return parsedLink{}, false
+	}
+
+	return parsedLink{
+		host: host,
+		path: strings.ToLower(u.Path),
+	}, true
+}
+
+// LinkHost — домен ссылки для белого списка (без www.) или пустая строка
+func LinkHost(link string) string {
+	p, ok := parseLink(link)
+	if !ok {
+		return ""
+	}
+	return strings.TrimPrefix(p.host, "www.")
+}
@@ -0,0 +1,101 @@
+package moderation
+
+import "testing"
+
+func TestAllowlistAllows(t *testing.T) {
+	list := NewAllowlist([]string{"ozon.ru", "wb.ru", "youtube.com", "мвд.рф", "t.me/ozon_official", "=mil.ru"})
+
+	tests := []struct {
+		link string
+		want bool
+	}{
+		// Разрешённые домены, поддомены и префиксы путей
+		{"ozon.ru", true},
+		{"https://ozon.ru/product/123", true},
+		{"www.ozon.ru", true},
+		{"https://www.ozon.ru/", true},
+		{"seller.ozon.ru/orders", true},
+		{"OZON.RU/Product", true},
+		{"ozon.ru.", true},
+		{"мвд.рф/news", true},
+		{"https://xn--b1aew.xn--p1ai/news", true},
+		{"t.me/ozon_official", true},
+		{"t.me/ozon_official/12", true},
+		{"https://t.me/ozon_official/12?single", true},
+		{"ozon.ru/catalog).", true},
+		{"mil.ru/news", true},
+		{"https://www.mil.ru/", true},
+
+		// Похожие, но чужие адреса
+		{"wb.ru.evil.com", false},
+		{"notozon.ru", false},
+		{"ozon.ru-sale.com", false},
+		{"evil.com/?r=youtube.com", false},
+		{"evil.com/#youtube.com", false},
+		{"https://youtube.com@evil.com/", false},
+		{"t.me/ozon_official_shop", false},
+		{"t.me/ozon", false},
+		{"t.me/spam_channel", false},
+		{"мвд.рф.evil.com", false},
+		{"evil.mil.ru", false},
+
+		// Не ссылки
+		{"", false},
+		{"ozon", false},
+		{"ftp://ozon.ru/file", false},
+	}
+	for _, tt := range tests {
+		if got := list.Allows(tt.link); got != tt.want {
+			t.Errorf("Allows(%q) = %v, want %v", tt.link, got, tt.want)
+		}
+	}
+}
+
+func TestParseAllowRule(t *testing.T) {
+	tests := []struct {
+		entry string
+		want  AllowRule
+		ok    bool
+	}{
+		{"ozon.ru", AllowRule{Host: "ozon.ru"}, true},
+		{"www.ozon.ru", AllowRule{Host: "ozon.ru"}, true},
+		{"https://t.me/Ozon_Official/", AllowRule{Host: "t.me", PathPrefix: "/ozon_official"}, true},
+		{"мвд.рф", AllowRule{Host: "xn--b1aew.xn--p1ai"}, true},
+		{"=ozon.ru", AllowRule{Host: "ozon.ru", Exact: true}, true},
+
+		// Записи из миграции 000003: mil.ru — публичный суффикс,
+		// поэтому миграция 000027 переписывает его в явную форму
+		{"mil.ru", AllowRule{}, false},
+		{"=mil.ru", AllowRule{Host: "mil.ru", Exact: true}, true},
+		{"gu.spb.ru", AllowRule{Host: "gu.spb.ru"}, true},
+		{"duma.gov.ru", AllowRule{Host: "duma.gov.ru"}, true},
+
+		// Правило на публичный суффикс разрешило бы любой сайт в зоне
+		{"com", AllowRule{}, false},
+		{"ru", AllowRule{}, false},
+		{"gov.ru", AllowRule{}, false},
+		{"co.uk", AllowRule{}, false},
+		{"github.io", AllowRule{}, false},
+
+		{"", AllowRule{}, false},
+		{"ftp://ozon.ru", AllowRule{}, false},
+		{"=", AllowRule{}, false},
+		{"=com", AllowRule{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := ParseAllowRule(tt.entry)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("ParseAllowRule(%q) = %+v, %v; want %+v, %v", tt.entry, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestNewAllowlistSkipsPublicSuffixes(t *testing.T) {
+	list := NewAllowlist([]string{"com", "gov.ru", "ozon.ru"})
+	if len(list) != 1 || list[0].Host != "ozon.ru" {
+		t.Fatalf("NewAllowlist = %+v, want only ozon.ru", list)
+	}
+	if list.Allows("evil.com") || list.Allows("phishing.gov.ru") {
+		t.Error("правило на публичный суффикс не должно разрешать сайты в зоне")
+	}
+}
@@ -0,0 +1,54 @@
+package moderation
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-telegram/bot/models"
+)
+
+// AnalyzeAttachments находит способы передать контакт в обход текста:
+// карточку контакта, место или геопозицию, историю и пересылку из канала.
+// Пересылка из канала, разрешённого белым списком (t.me/<username>), не считается.
+func AnalyzeAttachments(msg *models.Message, allowlist Allowlist) []Signal {
+	var signals []Signal
+
+	if c := msg.Contact; c != nil {
+		match := c.PhoneNumber
+		if match == "" {
+			match = strings.TrimSpace(c.FirstName + " " + c.LastName)
+		}
+		signals = append(signals, NewSignal(SignalContactCard, match))
+	}
+
+	switch {
+	case msg.Venue != nil:
+		signals = append(signals, NewSignal(SignalLocation, strings.TrimSpace(msg.Venue.Title+", "+msg.Venue.Address)))
+	case msg.Location != nil:
+		signals = append(signals, NewSignal(SignalLocation, fmt.Sprintf("%.5f,%.5f", msg.Location.Latitude, msg.Location.Longitude)))
+	}
+
+	if msg.Story != nil {
+		signals = append(signals, NewSignal(SignalStory, chatRef(&msg.Story.Chat)))
+	}
+
+	if o := msg.ForwardOrigin; o != nil && o.MessageOriginChannel != nil {
+		chat := &o.MessageOriginChannel.Chat
+		if chat.Username == "" || !allowlist.Allows("t.me/"+strings.ToLower(chat.Username)) {
+			signals = append(signals, NewSignal(SignalForward, chatRef(chat)))
+		}
+	}
+
+	return signals
+}
+
+// chatRef — ссылка на публичный чат или его название
+func chatRef(chat *models.Chat) string {
+	if chat.Username != "" {
+		return "t.me/" + chat.Username
+	}
+	if chat.Title != "" {
+		return chat.Title
+	}
+	return fmt.Sprintf("%d", chat.ID)
+}
@@ -0,0 +1,207 @@
+package moderation
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+const (
+	// Пока в каждом классе меньше примеров, классификатор молчит
+	bayesMinDocs = 20
+	// Сколько знакомых модели токенов нужно для оценки
+	bayesMinTokens = 3
+	// Вероятность спама, начиная с которой добавляется сигнал
+	bayesMinProbability = 0.8
+)
+
+// BayesCounts — в скольких спам- и не спам-сообщениях встретился токен
+type BayesCounts struct {
+	Spam int
+	Ham  int
+}
+
+// Bayes — наивный байесовский классификатор по токенам сообщения: основам
+// слов и парам соседних слов. Обучается офлайн командой bayes-train
+// и дообучается по решениям модераторов.
+type Bayes struct {
+	mu     sync.RWMutex
+	tokens map[string]BayesCounts
+	docs   BayesCounts
+}
+
+func NewBayes() *Bayes {
+	return &Bayes{tokens: make(map[string]BayesCounts)}
+}
+
+// Load заменяет модель копией tokens. Счётчики сообщений хранятся
+// под пустым токеном.
+func (b *Bayes) Load(tokens map[string]BayesCounts) {
+	model := make(map[string]BayesCounts, len(tokens))
+	for t, c := range tokens {
+		if t != "" {
+			model[t] = c
+		}
+	}
+
+	b.mu.Lock()
+	b.tokens, b.docs = model, tokens[""]
+	b.mu.Unlock()
+}
+
+// Learn учитывает (delta = 1) или забывает (delta = -1) токены сообщения
+func (b *Bayes) Learn(tokens []string, spam bool, delta int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.docs = addCounts(b.docs, spam, delta)
+	for _, t := range tokens {
+		c := addCounts(b.tokens[t], spam, delta)
+		if c.Spam == 0 && c.Ham == 0 {
+			delete(b.tokens, t)
+			continue
+		}
+		b.tokens[t] = c
+	}
+}
+
+func addCounts(c BayesCounts, spam bool, delta int) BayesCounts {
+	if spam {
+		c.Spam = max(c.Spam+delta, 0)
+	} else {
+		c.Ham = max(c.Ham+delta, 0)
+	}
+	return c
+}
+
+// Counts — копия модели, включая счётчики сообщений под пустым токеном
+func (b *Bayes) Counts() map[string]BayesCounts {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	out := make(map[string]BayesCounts, len(b.tokens)+1)
+	for t, c := range b.tokens {
+		out[t] = c
+	}
+	out[""] = b.docs
+	return out
+}
+
+// Probability — вероятность, что сообщение спам. false — модель не обучена
+// или в сообщении слишком мало знакомых ей токенов.
+//
+// Априорные вероятности классов считаются равными: нарушений в обучающей
+// выборке намного больше, чем одобренных сообщений, и без этого модель
+// находила бы спам везде.
+func (b *Bayes) Probability(tokens []string) (float64, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if b.docs.Spam < bayesMinDocs || b.docs.Ham < bayesMinDocs {
+		return 0, false
+	}
+
+	known := 0
+	logRatio := 0.0
+	for _, t := range tokens {
+		c, ok := b.tokens[t]
+		if !ok {
+			continue
+		}
+		known++
+		// Сглаживание Лапласа: токен, не встречавшийся в классе, не обнуляет оценку
+		pSpam := float64(c.Spam+1) / float64(b.docs.Spam+2)
+		pHam := float64(c.Ham+1) / float64(b.docs.Ham+2)
+		logRatio += math.Log(pSpam) - math.Log(pHam)
+	}
+	if known < bayesMinTokens {
+		return 0, false
+	}
+	return 1 / (1 + math.Exp(-logRatio)), true
+}
+
+// Signal — сигнал классификатора для текста. Вес растёт с вероятностью
+// спама: от порога предупреждения по умолчанию до полного веса при 100%.
+func (b *Bayes) Signal(text string) (Signal, bool) {
+	p, ok := b.Probability(Tokenize(text))
+	if !ok || p < bayesMinProbability {
+		return Signal{}, false
+	}
+	s := NewSignal(SignalBayes, fmt.Sprintf("%.0f%%", p*100))
+	s.Weight = int(math.Round(float64(s.Weight) * (p - 0.5) / 0.5))
+	return s, true
+}
+
+// Tokenize разбивает нормализованный текст на основы слов и пары соседних
+// основ. Каждый токен возвращается один раз; длинные числа заменяются
+// их длиной — важен сам факт номера, а не цифры.
+func Tokenize(text string) []string {
+	words := strings.FieldsFunc(Normalize(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	seen := make(map[string]bool)
+	var tokens []string
+	add := func(t string) {
+		if !seen[t] {
+			seen[t] = true
+			tokens = append(tokens, t)
+		}
+	}
+
+	prev := ""
+	for _, w := range words {
+		if len([]rune(w)) < 2 {
+			prev = ""
+			continue
+		}
+		stem := Stem(w)
+		if isDigits(w) {
+			stem = "#" + strconv.Itoa(len(w))
+		}
+		add(stem)
+		if prev != "" {
+			add(prev + " " + stem)
+		}
+		prev = stem
+	}
+	return tokens
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// Окончания русских слов от длинных к коротким: отрезается самое длинное
+var russianEndings = []string{
+	"иями", "ться",
+	"ями", "ами", "ого", "его", "ому", "ему", "ыми", "ими", "ешь", "ете", "ите", "ишь", "тся",
+	"ая", "яя", "ое", "ее", "ые", "ие", "ый", "ий", "ой", "ую", "юю", "ых", "их", "ом", "ем", "ам", "ям",
+	"ах", "ях", "ов", "ев", "ей", "ть", "ет", "ют", "ут", "ит", "ат", "ят", "им", "ла", "ло",
+	"ли", "ия", "ию", "ью",
+	"а", "я", "о", "е", "ы", "и", "у", "ю", "ь", "й",
+}
+
+// Stem — упрощённый стемминг русских слов: отрезает окончание, оставляя
+// основу не короче трёх букв: «удалённая», «удалённую», «удалённой» → «удалённ».
+// Слова не на кириллице не меняются.
+func Stem(word string) string {
+	runes := []rune(word)
+	if len(runes) < 4 || !unicode.Is(unicode.Cyrillic, runes[0]) {
+		return word
+	}
+	for _, ending := range russianEndings {
+		if strings.HasSuffix(word, ending) && len(runes)-len([]rune(ending)) >= 3 {
+			return strings.TrimSuffix(word, ending)
+		}
+	}
+	return word
+}
@@ -0,0 +1,101 @@
+package moderation
+
+import (
+	"fmt"
+	"maps"
+	"testing"
+)
+
+// trainBayes обучает модель так же, как bayes-train
+func trainBayes(spam, ham []string) *Bayes {
+	model := NewBayes()
+	for _, text := range spam {
+		model.Learn(Tokenize(text), true, 1)
+	}
+	for _, text := range ham {
+		model.Learn(Tokenize(text), false, 1)
+	}
+	return model
+}
+
+func bayesCorpus() (spam, ham []string) {
+	for i := 0; i < bayesMinDocs; i++ {
+		spam = append(spam,
+			fmt.Sprintf("Удалённая работа, доход от %d тысяч в день, пишите в личные сообщения", 5+i),
+			fmt.Sprintf("Быстрый заработок без вложений, %d%% гарантия, пишите в личку", 90+i%10),
+		)
+		ham = append(ham,
+			fmt.Sprintf("Продам велосипед, почти новый, %d рублей, самовывоз", 5000+i*100),
+			fmt.Sprintf("Подскажите хорошего мастера по ремонту стиральных машин в районе %d", i),
+		)
+	}
+	return spam, ham
+}
+
+func TestBayesRoundTrip(t *testing.T) {
+	spam, ham := bayesCorpus()
+	trained := trainBayes(spam, ham)
+
+	// Модель сохраняется в БД как Counts и читается обратно через Load
+	counts := trained.Counts()
+	saved := maps.Clone(counts)
+	loaded := NewBayes()
+	loaded.Load(counts)
+
+	if !maps.Equal(counts, saved) {
+		t.Error("Load изменил переданную карту")
+	}
+	if !maps.Equal(loaded.Counts(), saved) {
+		t.Error("модель после Load отличается от обученной")
+	}
+	if docs := loaded.Counts()[""]; docs.Spam != len(spam) || docs.Ham != len(ham) {
+		t.Errorf("счётчики сообщений = %+v, want %d спам и %d не спам", docs, len(spam), len(ham))
+	}
+
+	tests := []struct {
+		text string
+		spam bool
+	}{
+		{"Удалённая работа без вложений, доход от 10 тысяч, пишите в личку", true},
+		{"Быстрый заработок, пишите в личные сообщения", true},
+		{"Продам велосипед, самовывоз", false},
+		{"Подскажите мастера по ремонту стиральных машин", false},
+	}
+	for _, tt := range tests {
+		p, ok := loaded.Probability(Tokenize(tt.text))
+		if !ok {
+			t.Errorf("Probability(%q): модель не дала оценку", tt.text)
+			continue
+		}
+		if (p >= bayesMinProbability) != tt.spam {
+			t.Errorf("Probability(%q) = %.2f, want spam=%v", tt.text, p, tt.spam)
+		}
+		if _, ok := loaded.Signal(tt.text); ok != tt.spam {
+			t.Errorf("Signal(%q) = %v, want %v", tt.text, ok, tt.spam)
+		}
+	}
+}
+
+func TestBayesUntrained(t *testing.T) {
+	spam, ham := bayesCorpus()
+	// Пока в одном из классов мало примеров, классификатор молчит
+	model := trainBayes(spam, ham[:bayesMinDocs-1])
+	if _, ok := model.Probability(Tokenize(spam[0])); ok {
+		t.Error("Probability: модель с малой выборкой не должна давать оценку")
+	}
+}
+
+func TestBayesUnlearn(t *testing.T) {
+	spam, ham := bayesCorpus()
+	model := trainBayes(spam, ham)
+	before := model.Counts()
+
+	// Решение модератора отменено: учёт сообщения вычитается
+	tokens := Tokenize("Совершенно новое сообщение про доход")
+	model.Learn(tokens, true, 1)
+	model.Learn(tokens, true, -1)
+
+	if !maps.Equal(model.Counts(), before) {
+		t.Error("после отмены обучения модель отличается от исходной")
+	}
+}
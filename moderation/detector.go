@@ -5,24 +5,12 @@ import (
 	"strings"
 )
 
-type ViolationType string
-
-const (
-	ViolationPhone   ViolationType = "phone"
-	ViolationLink    ViolationType = "link"
-	ViolationContact ViolationType = "contact"
-)
-
-type Violation struct {
-	Type  ViolationType
-	Match string
-}
-
 var (
 	phonePatterns = []*regexp.Regexp{
 		regexp.MustCompile(`(?i)(\+7|8)[\s\-\(]*\d{3}[\s\-\)]*\d{3}[\s\-]*\d{2}[\s\-]*\d{2}`),
 		regexp.MustCompile(`(?i)\b\d{10,11}\b`),
 	}
+	digitPattern = regexp.MustCompile(`\d`)
 
 	// Любые ссылки
 	urlPattern = regexp.MustCompile(`(?i)https?://[^\s]+`)
@@ -32,66 +20,115 @@ var (
 	tmePattern = regexp.MustCompile(`(?i)t\.me/[a-zA-Z0-9_]+`)
 )
 
-// Check проверяет текст на нарушения
-func Check(text string, allowedDomains []string) *Violation {
-	textLower := strings.ToLower(text)
+// Analyze возвращает все сигналы спама в тексте: сначала в тексте как есть,
+// затем после нормализации. Сигналы, найденные только после нормализации,
+// заменяют обрывки того же совпадения в исходном тексте и дополняются
+// сигналом obfuscated — маскировка сама по себе подозрительна.
+func Analyze(text string, allowlist Allowlist) []Signal {
+	signals := collect(text, allowlist)
 
-	// 1. Проверка телефонов
-	for _, p := range phonePatterns {
-		if match := p.FindString(text); match != "" {
-			digits := regexp.MustCompile(`\d`).FindAllString(match, -1)
-			if len(digits) >= 10 {
-				return &Violation{
-					Type:  ViolationPhone,
-					Match: match,
-				}
+	normalized := Normalize(text)
+	if normalized == strings.ToLower(text) {
+		return signals
+	}
+
+	// Совпадения исходного текста сравниваются с нормализованными в том же виде
+	rawMatches := make([]string, len(signals))
+	for i, s := range signals {
+		rawMatches[i] = Normalize(s.Match)
+	}
+	fragment := make([]bool, len(signals))
+
+	// Белый список применяется только к ссылкам, написанным в тексте как есть:
+	// «оzоn.ru» с кириллической «о» — чужой домен, похожий на разрешённый
+	lower := strings.ToLower(text)
+	var extra []Signal
+	for _, s := range collect(normalized, nil) {
+		same := false
+		for i, raw := range signals {
+			// Тот же телефон после склейки цифр — не маскировка
+			if raw.Type == s.Type && rawMatches[i] == s.Match {
+				same = true
+				break
+			}
+		}
+		if same || (strings.Contains(lower, s.Match) && allowlist.Allows(s.Match)) {
+			continue
+		}
+		extra = append(extra, s)
+		// В исходном тексте нашёлся только кусок: «оzоn.ru» → «n.ru»
+		for i := range signals {
+			if rawMatches[i] != "" && strings.Contains(s.Match, rawMatches[i]) {
+				fragment[i] = true
 			}
 		}
 	}
+	if len(extra) == 0 {
+		return signals
+	}
 
-	// 2. Проверка ссылок с http/https
-	if urls := urlPattern.FindAllString(textLower, -1); len(urls) > 0 {
-		for _, url := range urls {
-			if !isAllowedURL(url, allowedDomains) {
-				return &Violation{
-					Type:  ViolationLink,
-					Match: url,
-				}
-			}
+	result := make([]Signal, 0, len(signals)+len(extra)+1)
+	for i, s := range signals {
+		if !fragment[i] {
+			result = append(result, s)
 		}
 	}
+	result = append(result, extra...)
+	return append(result, NewSignal(SignalObfuscated, ""))
+}
+
+// collect ищет карты, кошельки, телефоны, email, ссылки и контакты.
+// Совпадение, уже входящее в найденное (домен внутри ссылки или email,
+// «телефон» внутри номера карты), повторно не учитывается.
+func collect(text string, allowlist Allowlist) []Signal {
+	textLower := strings.ToLower(text)
+	var signals signalSet
+
+	// Номера карт и кошельки — раньше телефонов: их цифры похожи на номер
+	for _, match := range findCards(text) {
+		signals.add(NewSignal(SignalCard, match))
+	}
+	// Адреса base58 и EIP-55 чувствительны к регистру
+	for _, match := range findCryptoAddresses(text) {
+		signals.add(NewSignal(SignalCrypto, match))
+	}
 
-	// 3. Проверка доменов без http (mail.ru, vk.com и т.д.)
-	if domains := domainPattern.FindAllString(textLower, -1); len(domains) > 0 {
-		for _, domain := range domains {
-			if !isAllowedURL(domain, allowedDomains) {
-				return &Violation{
-					Type:  ViolationLink,
-					Match: domain,
-				}
+	// 1. Телефоны
+	for _, p := range phonePatterns {
+		for _, match := range p.FindAllString(text, -1) {
+			if len(digitPattern.FindAllString(match, -1)) >= 10 {
+				signals.add(NewSignal(SignalPhone, match))
 			}
 		}
 	}
 
-	// 4. Проверка t.me ссылок (удаляем все, кроме если t.me в белом списке)
-	if match := tmePattern.FindString(textLower); match != "" {
-		if !isAllowedURL("t.me", allowedDomains) {
-			return &Violation{
-				Type:  ViolationContact,
-				Match: match,
-			}
+	// Email — раньше доменов (кроме разрешённых)
+	for _, email := range emailPattern.FindAllString(textLower, -1) {
+		if !allowlist.Allows(email[strings.LastIndex(email, "@")+1:]) {
+			signals.add(NewSignal(SignalEmail, email))
 		}
 	}
 
-	return nil
-}
+	// 2. t.me ссылки на аккаунты (кроме разрешённых t.me или конкретных каналов)
+	for _, match := range tmePattern.FindAllString(textLower, -1) {
+		if !allowlist.Allows(match) {
+			signals.add(NewSignal(SignalContact, match))
+		}
+	}
 
-func isAllowedURL(url string, allowedDomains []string) bool {
-	urlLower := strings.ToLower(url)
-	for _, domain := range allowedDomains {
-		if strings.Contains(urlLower, strings.ToLower(domain)) {
-			return true
+	// 3. Ссылки с http/https
+	for _, url := range urlPattern.FindAllString(textLower, -1) {
+		if !allowlist.Allows(url) {
+			signals.add(NewSignal(SignalLink, url))
 		}
 	}
-	return false
+
+	// 4. Домены без http (mail.ru, vk.com и т.д.)
+	for _, domain := range domainPattern.FindAllString(textLower, -1) {
+		if !allowlist.Allows(domain) {
+			signals.add(NewSignal(SignalLink, domain))
+		}
+	}
+
+	return signals
 }
@@ -0,0 +1,134 @@
+package moderation
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/go-telegram/bot/models"
+)
+
+// MessageText — текст сообщения или подпись к медиа
+func MessageText(msg *models.Message) string {
+	if msg.Caption != "" {
+		return msg.Caption
+	}
+	return msg.Text
+}
+
+// AnalyzeMessage собирает сигналы из текста (или подписи) сообщения, его entities и кнопок
+func AnalyzeMessage(msg *models.Message, allowlist Allowlist) []Signal {
+	text, entities := msg.Text, msg.Entities
+	if msg.Caption != "" {
+		text, entities = msg.Caption, msg.CaptionEntities
+	}
+
+	var signals signalSet
+	if text != "" {
+		signals = signalSet(Analyze(text, allowlist))
+		for _, s := range AnalyzeEntities(text, entities, allowlist) {
+			signals.add(s)
+		}
+	}
+	for _, s := range AnalyzeButtons(msg.ReplyMarkup, allowlist) {
+		signals.add(s)
+	}
+	return signals
+}
+
+// AnalyzeButtons проверяет ссылки в кнопках. Кнопки бывают у сообщений,
+// отправленных через inline-ботов, — в них удобно прятать рекламу.
+func AnalyzeButtons(markup *models.InlineKeyboardMarkup, allowlist Allowlist) []Signal {
+	if markup == nil {
+		return nil
+	}
+
+	var signals signalSet
+	for _, row := range markup.InlineKeyboard {
+		for _, b := range row {
+			if b.URL == "" || allowlist.Allows(strings.ToLower(b.URL)) {
+				continue
+			}
+			s := NewSignal(SignalLink, b.URL)
+			s.Entity = "button"
+			signals.add(s)
+		}
+	}
+	return signals
+}
+
+// AnalyzeEntities проверяет entities сообщения. Адрес text_link не виден
+// в тексте вовсе — ссылка прячется за любым безобидным словом.
+func AnalyzeEntities(text string, entities []models.MessageEntity, allowlist Allowlist) []Signal {
+	var encoded []uint16
+	substr := func(e models.MessageEntity) string {
+		if encoded == nil {
+			encoded = utf16.Encode([]rune(text))
+		}
+		return entityText(encoded, e)
+	}
+
+	var signals signalSet
+	add := func(t SignalType, match string, e models.MessageEntity) {
+		s := NewSignal(t, match)
+		s.Entity = string(e.Type)
+		signals.add(s)
+	}
+
+	for _, e := range entities {
+		switch e.Type {
+		case models.MessageEntityTypeTextLink:
+			if !allowlist.Allows(strings.ToLower(e.URL)) {
+				add(SignalLink, e.URL, e)
+			}
+
+		case models.MessageEntityTypeURL:
+			url := substr(e)
+			if url != "" && !allowlist.Allows(strings.ToLower(url)) {
+				add(SignalLink, strings.ToLower(url), e)
+			}
+
+		case models.MessageEntityTypeEmail:
+			email := substr(e)
+			domain := email[strings.LastIndex(email, "@")+1:]
+			if email != "" && !allowlist.Allows(strings.ToLower(domain)) {
+				add(SignalEmail, email, e)
+			}
+
+		case models.MessageEntityTypeMention:
+			// Упоминание участника — обычное дело в ответах. Контактом считается
+			// только @бот: username ботов всегда оканчивается на «bot»
+			username := strings.TrimPrefix(substr(e), "@")
+			if username == "" || allowlist.Allows("t.me/"+strings.ToLower(username)) {
+				continue
+			}
+			if strings.HasSuffix(strings.ToLower(username), "bot") {
+				add(SignalContact, "@"+username, e)
+			} else {
+				add(SignalMention, "@"+username, e)
+			}
+
+		case models.MessageEntityTypeTextMention:
+			// Упоминание пользователя без username — ссылка на его профиль
+			if e.User == nil {
+				continue
+			}
+			link := fmt.Sprintf("tg://user?id=%d", e.User.ID)
+			if e.User.IsBot {
+				add(SignalContact, link, e)
+			} else {
+				add(SignalMention, link, e)
+			}
+		}
+	}
+
+	return signals
+}
+
+// entityText вырезает текст entity. Offset и Length заданы в UTF-16.
+func entityText(encoded []uint16, e models.MessageEntity) string {
+	if e.Offset < 0 || e.Length <= 0 || e.Offset+e.Length > len(encoded) {
+		return ""
+	}
+	return string(utf16.Decode(encoded[e.Offset : e.Offset+e.Length]))
+}
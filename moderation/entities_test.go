@@ -0,0 +1,70 @@
+package moderation
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/go-telegram/bot/models"
+)
+
+func TestAnalyzeEntities(t *testing.T) {
+	allowlist := NewAllowlist([]string{"ozon.ru", "t.me/ozon_official"})
+
+	// entity на весь текст; Offset и Length — в UTF-16
+	whole := func(typ models.MessageEntityType, text string) []models.MessageEntity {
+		return []models.MessageEntity{{Type: typ, Offset: 0, Length: len([]rune(text))}}
+	}
+
+	tests := []struct {
+		name     string
+		text     string
+		entities []models.MessageEntity
+		want     []SignalType
+	}{
+		// Ссылки
+		{"скрытая ссылка", "подробнее", []models.MessageEntity{{Type: models.MessageEntityTypeTextLink, Length: 9, URL: "https://spam.example/"}},
+			[]SignalType{SignalLink}},
+		{"скрытая разрешённая ссылка", "заказ", []models.MessageEntity{{Type: models.MessageEntityTypeTextLink, Length: 5, URL: "https://ozon.ru/item"}}, nil},
+		{"url", "avito.ru/item", whole(models.MessageEntityTypeURL, "avito.ru/item"), []SignalType{SignalLink}},
+		{"разрешённый url", "ozon.ru/item", whole(models.MessageEntityTypeURL, "ozon.ru/item"), nil},
+		{"email", "spam@mail.ru", whole(models.MessageEntityTypeEmail, "spam@mail.ru"), []SignalType{SignalEmail}},
+
+		// Упоминания
+		{"упоминание участника", "@ivan", whole(models.MessageEntityTypeMention, "@ivan"), []SignalType{SignalMention}},
+		{"упоминание бота", "@spam_bot", whole(models.MessageEntityTypeMention, "@spam_bot"), []SignalType{SignalContact}},
+		{"упоминание бота в верхнем регистре", "@SpamBot", whole(models.MessageEntityTypeMention, "@SpamBot"), []SignalType{SignalContact}},
+		{"разрешённый канал", "@ozon_official", whole(models.MessageEntityTypeMention, "@ozon_official"), nil},
+		{"упоминание без username", "Иван", []models.MessageEntity{{Type: models.MessageEntityTypeTextMention, Length: 4, User: &models.User{ID: 42}}},
+			[]SignalType{SignalMention}},
+		{"упоминание бота без username", "бот", []models.MessageEntity{{Type: models.MessageEntityTypeTextMention, Length: 3, User: &models.User{ID: 42, IsBot: true}}},
+			[]SignalType{SignalContact}},
+
+		// Offset в UTF-16: эмодзи занимает две позиции
+		{"упоминание после эмодзи", "👋 @ivan", []models.MessageEntity{{Type: models.MessageEntityTypeMention, Offset: 3, Length: 5}},
+			[]SignalType{SignalMention}},
+		{"entity за пределами текста", "@ivan", []models.MessageEntity{{Type: models.MessageEntityTypeMention, Offset: 3, Length: 5}}, nil},
+		{"форматирование", "жирный", whole(models.MessageEntityTypeBold, "жирный"), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var types []SignalType
+			for _, s := range AnalyzeEntities(tt.text, tt.entities, allowlist) {
+				types = append(types, s.Type)
+			}
+			if !slices.Equal(types, tt.want) {
+				t.Errorf("AnalyzeEntities(%q) = %v, want %v", tt.text, types, tt.want)
+			}
+		})
+	}
+}
+
+func TestMentionBelowThresholds(t *testing.T) {
+	// Ответ «@ivan, спасибо» не должен ни удаляться, ни предупреждаться
+	signals := AnalyzeEntities("@ivan, спасибо", []models.MessageEntity{{Type: models.MessageEntityTypeMention, Length: 5}}, nil)
+	if HasContent(signals) {
+		t.Error("упоминание участника не должно считаться сигналом по содержимому")
+	}
+	if v := Evaluate(signals, Thresholds{Warn: 30, Delete: 60}); v.Action != ActionNone {
+		t.Errorf("Evaluate = %s (%s), want без действия", v.Action, v.Explain())
+	}
+}
@@ -0,0 +1,282 @@
+package moderation
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/bits"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// FloodLimits — пороги детектора флуда и рейдов
+type FloodLimits struct {
+	UserMessages  int // сообщений от одного пользователя за Window
+	GroupMessages int // сообщений в группе за Window
+	Window        time.Duration
+	RaidUsers     int // разных пользователей с почти одинаковым текстом за RaidWindow
+	RaidWindow    time.Duration
+}
+
+type FloodKind string
+
+const (
+	FloodNone  FloodKind = ""
+	FloodUser  FloodKind = "user_flood"
+	FloodGroup FloodKind = "group_flood"
+	FloodRaid  FloodKind = "raid"
+)
+
+// Тексты короче не сравниваются: «привет» и «спасибо» повторяются естественно
+const raidMinLen = 20
+
+// Сколько бит отпечатков могут различаться у почти одинаковых текстов
+const raidMaxDistance = 8
+
+// FloodMessage — сообщение, учтённое детектором
+type FloodMessage struct {
+	ChatID    int64
+	UserID    int64
+	MessageID int
+	Text      string
+	At        time.Time
+
+	fingerprint uint64
+	handled     bool // уже вошло в одно из срабатываний
+}
+
+// FloodResult — срабатывание детектора
+type FloodResult struct {
+	Kind FloodKind
+	// Count — сообщений (для флуда) или пользователей (для рейда) в окне
+	Count int
+	// Messages — ещё не обработанные сообщения, вызвавшие срабатывание
+	Messages []FloodMessage
+	// Users — авторы этих сообщений
+	Users []int64
+	// First — первое срабатывание такого вида за окно: повод для алерта
+	First bool
+}
+
+// FloodDetector считает сообщения в скользящем окне по пользователю и группе
+// и ищет почти одинаковые тексты от разных пользователей. Состояние хранится
+// в памяти инстанса, поэтому детектор рассчитан на один инстанс, получающий
+// апдейты: при нескольких каждый видит только свою долю сообщений.
+type FloodDetector struct {
+	limits FloodLimits
+
+	mu       sync.Mutex
+	groups   map[int64][]*FloodMessage
+	triggers map[string]time.Time // последнее срабатывание по виду и ключу
+}
+
+func NewFloodDetector(limits FloodLimits) *FloodDetector {
+	return &FloodDetector{
+		limits:   limits,
+		groups:   make(map[int64][]*FloodMessage),
+		triggers: make(map[string]time.Time),
+	}
+}
+
+// Add учитывает сообщение и возвращает самое серьёзное срабатывание:
+// рейд, затем флуд пользователя, затем флуд группы
+func (d *FloodDetector) Add(m FloodMessage) FloodResult {
+	if m.At.IsZero() {
+		m.At = time.Now()
+	}
+	if len([]rune(m.Text)) >= raidMinLen {
+		m.fingerprint = Fingerprint(m.Text)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	recent := d.prune(m.ChatID, m.At)
+	msg := &m
+	recent = append(recent, msg)
+	d.groups[m.ChatID] = recent
+
+	if res := d.raid(recent, msg); res.Kind != FloodNone {
+		return res
+	}
+
+	var userMsgs, groupMsgs []*FloodMessage
+	for _, r := range recent {
+		if m.At.Sub(r.At) > d.limits.Window {
+			continue
+		}
+		groupMsgs = append(groupMsgs, r)
+		if r.UserID == m.UserID {
+			userMsgs = append(userMsgs, r)
+		}
+	}
+
+	if d.limits.UserMessages > 0 && len(userMsgs) > d.limits.UserMessages {
+		return d.result(FloodUser, fmt.Sprintf("%d:%d", m.ChatID, m.UserID), len(userMsgs), userMsgs, m.At, d.limits.Window)
+	}
+	if d.limits.GroupMessages > 0 && len(groupMsgs) > d.limits.GroupMessages {
+		// Флуд группы — много разных людей: удалять нечего, сообщения не помечаем
+		return d.result(FloodGroup, fmt.Sprintf("%d", m.ChatID), len(groupMsgs), nil, m.At, d.limits.Window)
+	}
+	return FloodResult{}
+}
+
+// raid ищет в окне почти одинаковые тексты от разных пользователей
+func (d *FloodDetector) raid(recent []*FloodMessage, m *FloodMessage) FloodResult {
+	if m.fingerprint == 0 || d.limits.RaidUsers <= 1 {
+		return FloodResult{}
+	}
+
+	var similar []*FloodMessage
+	users := make(map[int64]bool)
+	for _, r := range recent {
+		if r.fingerprint == 0 || m.At.Sub(r.At) > d.limits.RaidWindow {
+			continue
+		}
+		if bits.OnesCount64(r.fingerprint^m.fingerprint) <= raidMaxDistance {
+			similar = append(similar, r)
+			users[r.UserID] = true
+		}
+	}
+	if len(users) < d.limits.RaidUsers {
+		return FloodResult{}
+	}
+	return d.result(FloodRaid, fmt.Sprintf("%d", m.ChatID), len(users), similar, m.At, d.limits.RaidWindow)
+}
+
+func (d *FloodDetector) result(kind FloodKind, key string, count int, msgs []*FloodMessage, now time.Time, window time.Duration) FloodResult {
+	res := FloodResult{Kind: kind, Count: count}
+
+	triggerKey := string(kind) + ":" + key
+	last, ok := d.triggers[triggerKey]
+	res.First = !ok || now.Sub(last) > window
+	d.triggers[triggerKey] = now
+
+	seen := make(map[int64]bool)
+	for _, r := range msgs {
+		if r.handled {
+			continue
+		}
+		r.handled = true
+		res.Messages = append(res.Messages, *r)
+		if !seen[r.UserID] {
+			seen[r.UserID] = true
+			res.Users = append(res.Users, r.UserID)
+		}
+	}
+	return res
+}
+
+// prune убирает из группы сообщения старше обоих окон
+func (d *FloodDetector) prune(chatID int64, now time.Time) []*FloodMessage {
+	keep := max(d.limits.Window, d.limits.RaidWindow)
+	recent := d.groups[chatID]
+	i := 0
+	for i < len(recent) && now.Sub(recent[i].At) > keep {
+		i++
+	}
+	recent = recent[i:]
+
+	for k, t := range d.triggers {
+		if now.Sub(t) > keep {
+			delete(d.triggers, k)
+		}
+	}
+	return recent
+}
+
+// SlowModes — медленный режим, который включил бот. У ботов нет метода
+// для настройки slow mode группы, поэтому лишние сообщения удаляет сам бот.
+// Как и FloodDetector, хранится в памяти инстанса.
+type SlowModes struct {
+	mu     sync.Mutex
+	groups map[int64]*slowMode
+}
+
+type slowMode struct {
+	until    time.Time
+	lastSent map[int64]time.Time // последнее разрешённое сообщение пользователя
+}
+
+func NewSlowModes() *SlowModes {
+	return &SlowModes{groups: make(map[int64]*slowMode)}
+}
+
+// Enable включает медленный режим в группе до until или продлевает включённый
+func (s *SlowModes) Enable(chatID int64, now, until time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sm, ok := s.groups[chatID]
+	if !ok || now.After(sm.until) {
+		sm = &slowMode{lastSent: make(map[int64]time.Time)}
+		s.groups[chatID] = sm
+	}
+	sm.until = until
+}
+
+// TooSoon сообщает, что в группе медленный режим, а пользователь писал
+// меньше delay назад. Разрешённое сообщение запоминается.
+func (s *SlowModes) TooSoon(chatID, userID int64, now time.Time, delay time.Duration) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sm, ok := s.groups[chatID]
+	if !ok {
+		return false
+	}
+	if now.After(sm.until) {
+		delete(s.groups, chatID)
+		return false
+	}
+
+	last, seen := sm.lastSent[userID]
+	if seen && now.Sub(last) < delay {
+		return true
+	}
+	sm.lastSent[userID] = now
+	return false
+}
+
+// Fingerprint — SimHash нормализованного текста по триграммам символов.
+// Знаки препинания и эмодзи отбрасываются, похожие буквы сводятся к одной,
+// поэтому замена «а» на латинскую «a» отпечаток не меняет. У текстов,
+// отличающихся парой слов или символов, отпечатки отличаются в немногих битах.
+func Fingerprint(text string) uint64 {
+	letters := strings.Map(func(r rune) rune {
+		if l, ok := homoglyphs[r]; ok {
+			return l
+		}
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return ' '
+	}, Normalize(text))
+	runes := []rune(strings.Join(strings.Fields(letters), " "))
+	if len(runes) < 3 {
+		return 0
+	}
+
+	var weights [64]int
+	for i := 0; i+3 <= len(runes); i++ {
+		h := fnv.New64a()
+		h.Write([]byte(string(runes[i : i+3])))
+		sum := h.Sum64()
+		for b := 0; b < 64; b++ {
+			if sum&(1<<b) != 0 {
+				weights[b]++
+			} else {
+				weights[b]--
+			}
+		}
+	}
+
+	var fp uint64
+	for b := 0; b < 64; b++ {
+		if weights[b] > 0 {
+			fp |= 1 << b
+		}
+	}
+	return fp
+}
@@ -0,0 +1,187 @@
+package moderation
+
+import (
+	"math/bits"
+	"slices"
+	"testing"
+	"time"
+)
+
+var floodStart = time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+func at(seconds int) time.Time {
+	return floodStart.Add(time.Duration(seconds) * time.Second)
+}
+
+func TestFloodUserWindow(t *testing.T) {
+	d := NewFloodDetector(FloodLimits{UserMessages: 3, Window: 10 * time.Second})
+
+	// Три сообщения в окне — ещё не флуд
+	for i := 1; i <= 3; i++ {
+		if res := d.Add(FloodMessage{ChatID: 1, UserID: 10, MessageID: i, At: at(i)}); res.Kind != FloodNone {
+			t.Fatalf("сообщение %d: %s, want без срабатывания", i, res.Kind)
+		}
+	}
+
+	// Четвёртое — флуд: удаляются все сообщения окна, это первое срабатывание
+	res := d.Add(FloodMessage{ChatID: 1, UserID: 10, MessageID: 4, At: at(4)})
+	if res.Kind != FloodUser || res.Count != 4 || !res.First {
+		t.Fatalf("Add = %+v, want user_flood, 4 сообщения, первое срабатывание", res)
+	}
+	if got := messageIDs(res); !slices.Equal(got, []int{1, 2, 3, 4}) {
+		t.Errorf("сообщения = %v, want [1 2 3 4]", got)
+	}
+	if !slices.Equal(res.Users, []int64{10}) {
+		t.Errorf("пользователи = %v, want [10]", res.Users)
+	}
+
+	// Следующее — только новое сообщение, алерт уже был
+	res = d.Add(FloodMessage{ChatID: 1, UserID: 10, MessageID: 5, At: at(5)})
+	if res.Kind != FloodUser || res.First || !slices.Equal(messageIDs(res), []int{5}) {
+		t.Errorf("Add = %+v, want повторное user_flood только с сообщением 5", res)
+	}
+
+	// Другой пользователь и другая группа считаются отдельно
+	if res := d.Add(FloodMessage{ChatID: 1, UserID: 20, MessageID: 6, At: at(6)}); res.Kind != FloodNone {
+		t.Errorf("другой пользователь: %s, want без срабатывания", res.Kind)
+	}
+	if res := d.Add(FloodMessage{ChatID: 2, UserID: 10, MessageID: 7, At: at(6)}); res.Kind != FloodNone {
+		t.Errorf("другая группа: %s, want без срабатывания", res.Kind)
+	}
+
+	// Сообщения старше окна не учитываются
+	if res := d.Add(FloodMessage{ChatID: 1, UserID: 10, MessageID: 8, At: at(30)}); res.Kind != FloodNone {
+		t.Errorf("после окна: %s, want без срабатывания", res.Kind)
+	}
+}
+
+func TestFloodGroup(t *testing.T) {
+	d := NewFloodDetector(FloodLimits{UserMessages: 5, GroupMessages: 3, Window: 10 * time.Second})
+
+	var res FloodResult
+	for i := 1; i <= 4; i++ {
+		res = d.Add(FloodMessage{ChatID: 1, UserID: int64(i), MessageID: i, At: at(i)})
+	}
+	// Пишут разные люди — удалять нечего
+	if res.Kind != FloodGroup || res.Count != 4 || !res.First || len(res.Messages) != 0 {
+		t.Errorf("Add = %+v, want group_flood без сообщений для удаления", res)
+	}
+}
+
+func TestFloodRaid(t *testing.T) {
+	d := NewFloodDetector(FloodLimits{RaidUsers: 3, RaidWindow: time.Minute})
+
+	// Вариации одного текста: знаки, эмодзи, регистр, латинские буквы
+	texts := []string{
+		"Заработок от 5000 в день без вложений, пишите в личку",
+		"ЗАРАБОТОК от 5000 в день без вложений 🔥 пишите в личку",
+		"Зaрaбoтoк от 7000 в день без вложений, пишите в личку!!!",
+	}
+
+	// Один пользователь, повторяющий текст, — не рейд
+	for i, text := range texts {
+		if res := d.Add(FloodMessage{ChatID: 1, UserID: 10, MessageID: i + 1, Text: text, At: at(i)}); res.Kind != FloodNone {
+			t.Fatalf("один пользователь: %s, want без срабатывания", res.Kind)
+		}
+	}
+
+	// Разный текст от других пользователей не объединяется
+	for i, text := range []string{"Продам велосипед, почти новый, самовывоз", "Подскажите мастера по ремонту стиральных машин"} {
+		if res := d.Add(FloodMessage{ChatID: 1, UserID: int64(20 + i), MessageID: 10 + i, Text: text, At: at(5)}); res.Kind != FloodNone {
+			t.Fatalf("разные тексты: %s, want без срабатывания", res.Kind)
+		}
+	}
+
+	if res := d.Add(FloodMessage{ChatID: 1, UserID: 30, MessageID: 20, Text: texts[1], At: at(10)}); res.Kind != FloodNone {
+		t.Fatalf("два пользователя: %s, want без срабатывания", res.Kind)
+	}
+	res := d.Add(FloodMessage{ChatID: 1, UserID: 40, MessageID: 21, Text: texts[2], At: at(11)})
+	if res.Kind != FloodRaid || res.Count != 3 || !res.First {
+		t.Fatalf("Add = %+v, want raid от 3 пользователей", res)
+	}
+	if got := messageIDs(res); !slices.Equal(got, []int{1, 2, 3, 20, 21}) {
+		t.Errorf("сообщения = %v, want [1 2 3 20 21]", got)
+	}
+	if !slices.Equal(res.Users, []int64{10, 30, 40}) {
+		t.Errorf("пользователи = %v, want [10 30 40]", res.Users)
+	}
+
+	// За пределами окна рейд не продолжается
+	if res := d.Add(FloodMessage{ChatID: 1, UserID: 50, MessageID: 22, Text: texts[0], At: at(120)}); res.Kind != FloodNone {
+		t.Errorf("после окна: %s, want без срабатывания", res.Kind)
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	base := Fingerprint("Заработок от 5000 в день без вложений, пишите в личку")
+
+	tests := []struct {
+		text    string
+		similar bool
+	}{
+		{"Заработок от 5000 в день без вложений, пишите в личку!!!", true},
+		{"ЗАРАБОТОК от 5000 в день без вложений 🔥 пишите в личку", true},
+		{"Зaрaбoтoк от 5000 в день без вложений, пишите в личку", true},
+		{"Заработок от 7000 в день без вложений, пишите в личку", true},
+		{"Продам велосипед, почти новый, самовывоз с Ленина", false},
+		{"Подскажите хорошего мастера по ремонту стиральных машин", false},
+	}
+	for _, tt := range tests {
+		d := bits.OnesCount64(base ^ Fingerprint(tt.text))
+		if (d <= raidMaxDistance) != tt.similar {
+			t.Errorf("расстояние до %q = %d, want similar=%v", tt.text, d, tt.similar)
+		}
+	}
+
+	if fp := Fingerprint("!!"); fp != 0 {
+		t.Errorf("Fingerprint короткого текста = %#x, want 0", fp)
+	}
+}
+
+func TestSlowModes(t *testing.T) {
+	s := NewSlowModes()
+	delay := 30 * time.Second
+
+	if s.TooSoon(1, 10, at(0), delay) || s.TooSoon(1, 10, at(1), delay) {
+		t.Fatal("без медленного режима сообщения не удаляются")
+	}
+
+	s.Enable(1, at(0), at(300))
+	tests := []struct {
+		name    string
+		chatID  int64
+		userID  int64
+		at      time.Time
+		tooSoon bool
+	}{
+		{"первое сообщение", 1, 10, at(10), false},
+		{"раньше задержки", 1, 10, at(20), true},
+		{"удалённое не сдвигает отсчёт", 1, 10, at(39), true},
+		{"после задержки", 1, 10, at(40), false},
+		{"другой пользователь", 1, 20, at(41), false},
+		{"другая группа", 2, 10, at(41), false},
+		{"перед окончанием", 1, 10, at(295), false},
+		{"режим закончился", 1, 10, at(301), false},
+	}
+	for _, tt := range tests {
+		if got := s.TooSoon(tt.chatID, tt.userID, tt.at, delay); got != tt.tooSoon {
+			t.Errorf("%s: TooSoon = %v, want %v", tt.name, got, tt.tooSoon)
+		}
+	}
+
+	// Повторное включение продлевает режим и сохраняет историю
+	s.Enable(3, at(0), at(100))
+	s.TooSoon(3, 10, at(90), delay)
+	s.Enable(3, at(95), at(200))
+	if !s.TooSoon(3, 10, at(110), delay) {
+		t.Error("после продления: TooSoon = false, want true")
+	}
+}
+
+func messageIDs(res FloodResult) []int {
+	var ids []int
+	for _, m := range res.Messages {
+		ids = append(ids, m.MessageID)
+	}
+	return ids
+}
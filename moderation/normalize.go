@@ -0,0 +1,234 @@
+package moderation
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Normalize приводит текст к виду, в котором обычные шаблоны находят
+// замаскированные контакты:
+//   - NFKC: полноширинные, надстрочные, «жирные» и обведённые цифры → обычные;
+//   - удаляются zero-width, управляющие и комбинируемые символы (зачёркивание, 9️⃣);
+//   - в словах, где смешаны латиница и кириллица/греческий, похожие буквы
+//     заменяются латинскими («оzоn» → «ozon»);
+//   - числительные словами → цифры («восемь девятьсот девять», «9one9»);
+//   - цифры, разбитые точками, эмодзи и прочими разделителями, склеиваются,
+//     если получается номер телефона.
+func Normalize(text string) string {
+	text = norm.NFKC.String(text)
+	text = stripInvisible(text)
+	text = strings.ToLower(text)
+	text = foldHomoglyphs(text)
+	text = replaceNumerals(text)
+	return joinDigitGroups(text)
+}
+
+// stripInvisible удаляет невидимые и «украшающие» символы
+func stripInvisible(text string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf):
+			return -1
+		case unicode.Is(unicode.Variation_Selector, r):
+			return -1
+		}
+		return r
+	}, text)
+}
+
+// homoglyphs — кириллические и греческие буквы, неотличимые от латинских
+var homoglyphs = map[rune]rune{
+	// Кириллица
+	'а': 'a', 'в': 'b', 'е': 'e', 'ё': 'e', 'к': 'k', 'м': 'm', 'н': 'h',
+	'о': 'o', 'р': 'p', 'с': 'c', 'т': 't', 'у': 'y', 'х': 'x', 'і': 'i',
+	'ї': 'i', 'ј': 'j', 'ѕ': 's', 'ԁ': 'd', 'ԛ': 'q', 'ԝ': 'w', 'һ': 'h',
+	'ѵ': 'v', 'ғ': 'f',
+	// Греческий
+	'α': 'a', 'β': 'b', 'ε': 'e', 'η': 'n', 'ι': 'i', 'κ': 'k', 'ν': 'v',
+	'ο': 'o', 'ρ': 'p', 'τ': 't', 'υ': 'u', 'χ': 'x', 'γ': 'y', 'ω': 'w',
+}
+
+// foldHomoglyphs заменяет похожие буквы латинскими только в словах со смешанной
+// письменностью: обычный русский текст и кириллические домены (мвд.рф) не трогаем
+func foldHomoglyphs(text string) string {
+	runes := []rune(text)
+	for start := 0; start < len(runes); {
+		if !unicode.IsLetter(runes[start]) {
+			start++
+			continue
+		}
+		end := start
+		hasLatin, hasOther := false, false
+		for end < len(runes) && unicode.IsLetter(runes[end]) {
+			if unicode.Is(unicode.Latin, runes[end]) {
+				hasLatin = true
+			} else if _, ok := homoglyphs[runes[end]]; ok {
+				hasOther = true
+			}
+			end++
+		}
+		if hasLatin && hasOther {
+			for i := start; i < end; i++ {
+				if l, ok := homoglyphs[runes[i]]; ok {
+					runes[i] = l
+				}
+			}
+		}
+		start = end
+	}
+	return string(runes)
+}
+
+// numeralWords — числительные, которыми прописывают номера телефонов
+var numeralWords = map[string]int{
+	"ноль": 0, "нуль": 0, "один": 1, "одна": 1, "одно": 1, "два": 2, "две": 2,
+	"три": 3, "четыре": 4, "пять": 5, "шесть": 6, "семь": 7, "восемь": 8, "девять": 9,
+	"десять": 10, "одиннадцать": 11, "двенадцать": 12, "тринадцать": 13,
+	"четырнадцать": 14, "пятнадцать": 15, "шестнадцать": 16, "семнадцать": 17,
+	"восемнадцать": 18, "девятнадцать": 19,
+	"двадцать": 20, "тридцать": 30, "сорок": 40, "пятьдесят": 50, "шестьдесят": 60,
+	"семьдесят": 70, "восемьдесят": 80, "девяносто": 90,
+	"сто": 100, "двести": 200, "триста": 300, "четыреста": 400, "пятьсот": 500,
+	"шестьсот": 600, "семьсот": 700, "восемьсот": 800, "девятьсот": 900,
+
+	"zero": 0, "one": 1, "two": 2, "three": 3, "four": 4, "five": 5, "six": 6,
+	"seven": 7, "eight": 8, "nine": 9, "ten": 10, "eleven": 11, "twelve": 12,
+	"thirteen": 13, "fourteen": 14, "fifteen": 15, "sixteen": 16, "seventeen": 17,
+	"eighteen": 18, "nineteen": 19, "twenty": 20, "thirty": 30, "forty": 40,
+	"fifty": 50, "sixty": 60, "seventy": 70, "eighty": 80, "ninety": 90,
+}
+
+// replaceNumerals заменяет числительные цифрами. Слово должно быть целым:
+// границей считается всё, кроме букв, поэтому «9one9» → «919», а «phone» не трогаем.
+// Составные числа собираются: «девятьсот девяносто девять» → «999».
+func replaceNumerals(text string) string {
+	var b strings.Builder
+	runes := []rune(text)
+
+	// Незавершённое составное число и разделители после него
+	value, lastPlace := -1, 0
+	pending := ""
+	flush := func() {
+		if value >= 0 {
+			b.WriteString(strconv.Itoa(value))
+			value = -1
+		}
+		b.WriteString(pending)
+		pending = ""
+	}
+
+	for i := 0; i < len(runes); {
+		if !unicode.IsLetter(runes[i]) {
+			if value >= 0 && !unicode.IsDigit(runes[i]) {
+				pending += string(runes[i])
+			} else {
+				flush()
+				b.WriteRune(runes[i])
+			}
+			i++
+			continue
+		}
+
+		end := i
+		for end < len(runes) && unicode.IsLetter(runes[end]) {
+			end++
+		}
+		word := string(runes[i:end])
+		i = end
+
+		n, ok := numeralWords[word]
+		if !ok {
+			flush()
+			b.WriteString(word)
+			continue
+		}
+
+		place := numeralPlace(n)
+		// «девяносто» после «девятьсот» продолжает число, «девять» после «девять» — нет
+		if value >= 0 && place < lastPlace && !(lastPlace == 10 && n >= 10) {
+			value += n
+			lastPlace = place
+			pending = ""
+			continue
+		}
+		flush()
+		value, lastPlace = n, place
+	}
+	flush()
+	return b.String()
+}
+
+// numeralPlace — разряд числительного: 100, 10 или 1 (10–19 занимают оба младших)
+func numeralPlace(n int) int {
+	switch {
+	case n >= 100:
+		return 100
+	case n >= 20:
+		return 10
+	}
+	return 1
+}
+
+// Максимальная длина разделителя между цифрами номера («9 . 1», «9🔥1»)
+const maxDigitSeparator = 3
+
+// joinDigitGroups склеивает цифры, разделённые короткими разделителями без букв,
+// если группа похожа на российский номер. Цены и даты («3800, 01.02.2024») не трогаем.
+func joinDigitGroups(text string) string {
+	runes := []rune(text)
+	var b strings.Builder
+
+	for i := 0; i < len(runes); {
+		if !unicode.IsDigit(runes[i]) {
+			b.WriteRune(runes[i])
+			i++
+			continue
+		}
+
+		// Собираем группу: цифры и короткие разделители между ними
+		var digits []rune
+		end := i
+		for j := i; j < len(runes); {
+			if unicode.IsDigit(runes[j]) {
+				digits = append(digits, runes[j])
+				j++
+				end = j
+				continue
+			}
+			k := j
+			for k < len(runes) && k-j < maxDigitSeparator && isDigitSeparator(runes[k]) {
+				k++
+			}
+			if k == j || k >= len(runes) || !unicode.IsDigit(runes[k]) {
+				break
+			}
+			j = k
+		}
+
+		if isPhoneDigits(digits) {
+			b.WriteString(string(digits))
+		} else {
+			b.WriteString(string(runes[i:end]))
+		}
+		i = end
+	}
+	return b.String()
+}
+
+func isDigitSeparator(r rune) bool {
+	return r != '\n' && !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
+
+// isPhoneDigits — 8/7 и 10 цифр или 9 и ещё 9 цифр (номер без кода страны)
+func isPhoneDigits(digits []rune) bool {
+	switch len(digits) {
+	case 11:
+		return digits[0] == '7' || digits[0] == '8'
+	case 10:
+		return digits[0] == '9'
+	}
+	return false
+}
@@ -0,0 +1,111 @@
+package moderation
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		// Телефоны словами
+		{"числительные", "восемь девять девять девять один два три четыре пять шесть семь", "89991234567"},
+		{"составные числительные", "восемь девятьсот девяносто девять сто двадцать три сорок пять шестьдесят семь", "89991234567"},
+		{"числительные через запятую", "восемь, девятьсот девяносто девять, сто двадцать три, сорок пять, шестьдесят семь", "89991234567"},
+		{"английские числительные", "eight nine nine nine one two three four five six seven", "89991234567"},
+		{"цифры вперемешку со словами", "9one9 1234567", "9191234567"},
+		{"слово с числительным внутри не трогаем", "phone", "phone"},
+
+		// Разделители и эмодзи между цифрами
+		{"эмодзи-цифры", "8️⃣9️⃣9️⃣9️⃣1️⃣2️⃣3️⃣4️⃣5️⃣6️⃣7️⃣", "89991234567"},
+		{"эмодзи между цифрами", "8🔥999🔥123🔥45🔥67", "89991234567"},
+		{"точки", "8.999.123.45.67", "89991234567"},
+		{"пробелы и скобки", "+7 (999) 123 45 67", "+79991234567"},
+		{"zero-width", "8​999​123​45​67", "89991234567"},
+		{"зачёркивание", "8̶999̶1234567", "89991234567"},
+
+		// Полноширинные и стилизованные символы
+		{"полноширинные цифры", "８９９９１２３４５６７", "89991234567"},
+		{"полноширинный домен", "ｏｚｏｎ．ｒｕ", "ozon.ru"},
+		{"математические цифры", "𝟖𝟗𝟗𝟗𝟏𝟐𝟑𝟒𝟓𝟔𝟕", "89991234567"},
+		{"обведённые цифры", "⑧⑨⑨⑨①②③④⑤⑥⑦", "89991234567"},
+
+		// Смешанная письменность
+		{"кириллица в латинском домене", "оzоn.ru", "ozon.ru"},
+		{"греческий в латинском слове", "tεlegram", "telegram"},
+		{"кириллица в t.me", "t.me/sрam_bot", "t.me/spam_bot"},
+		{"кириллица рядом с цифрами", "тел 8999123456７", "тел 89991234567"},
+
+		// Обычный текст не меняется
+		{"русский текст", "Продам велосипед, торг", "продам велосипед, торг"},
+		{"кириллический домен", "мвд.рф", "мвд.рф"},
+		{"цены и даты", "цена 3800, 01.02.2024", "цена 3800, 01.02.2024"},
+		{"короткие числа", "2 комнаты, 45 м², 3 этаж", "2 комнаты, 45 м2, 3 этаж"},
+		{"число без кода", "артикул 12345678901", "артикул 12345678901"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Normalize(tt.text); got != tt.want {
+				t.Errorf("Normalize(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAnalyzeObfuscated(t *testing.T) {
+	allowlist := NewAllowlist([]string{"ozon.ru"})
+
+	tests := []struct {
+		name  string
+		text  string
+		types []SignalType // по порядку
+		match string       // совпадение первого сигнала
+	}{
+		// Без маскировки
+		{"телефон", "звоните 8 (999) 123-45-67", []SignalType{SignalPhone}, "8 (999) 123-45-67"},
+		{"ссылка", "заходите на avito.ru", []SignalType{SignalLink}, "avito.ru"},
+		{"разрешённый домен", "заказ на ozon.ru", nil, ""},
+		{"обычный текст", "Продам велосипед, торг", nil, ""},
+		{"цена", "цена 3800, 01.02.2024", nil, ""},
+
+		// Телефоны
+		{"телефон словами", "пишите восемь девятьсот девяносто девять сто двадцать три сорок пять шестьдесят семь",
+			[]SignalType{SignalPhone, SignalObfuscated}, "89991234567"},
+		{"эмодзи-цифры", "8️⃣9️⃣9️⃣9️⃣1️⃣2️⃣3️⃣4️⃣5️⃣6️⃣7️⃣", []SignalType{SignalPhone, SignalObfuscated}, "89991234567"},
+		{"эмодзи между цифрами", "8🔥999🔥123🔥45🔥67", []SignalType{SignalPhone, SignalObfuscated}, "89991234567"},
+		{"полноширинные цифры", "８９９９１２３４５６７", []SignalType{SignalPhone, SignalObfuscated}, "89991234567"},
+		{"смешанные цифры", "тел 8999123456７", []SignalType{SignalPhone, SignalObfuscated}, "89991234567"},
+		{"второй телефон замаскирован", "+7 999 123 45 67 или 8🔥999🔥765🔥43🔥21",
+			[]SignalType{SignalPhone, SignalPhone, SignalObfuscated}, "+7 999 123 45 67"},
+
+		// Ссылки
+		{"кириллица в домене", "заходите на оzоn.ru", []SignalType{SignalLink, SignalObfuscated}, "ozon.ru"},
+		{"кириллица в чужом домене", "заходите на wildbеrriеs.ru", []SignalType{SignalLink, SignalObfuscated}, "wildberries.ru"},
+		{"разрешённый домен рядом с маскировкой", "ozon.ru, звоните 8🔥999🔥123🔥45🔥67",
+			[]SignalType{SignalPhone, SignalObfuscated}, "89991234567"},
+		{"кириллица в домене с путём", "аvitо.ru/item", []SignalType{SignalLink, SignalObfuscated}, "avito.ru/item"},
+		{"полноширинный домен", "ｏｚｏｎ．ｒｕ", []SignalType{SignalLink, SignalObfuscated}, "ozon.ru"},
+		{"полноширинный чужой домен", "ａｖｉｔｏ．ｒｕ", []SignalType{SignalLink, SignalObfuscated}, "avito.ru"},
+		{"кириллица в t.me", "t.me/sрam_bot", []SignalType{SignalContact, SignalObfuscated}, "t.me/spam_bot"},
+		{"телефон и ссылка", "x.ru и 8 999 123 45 67 и оzоn.ru",
+			[]SignalType{SignalPhone, SignalLink, SignalLink, SignalObfuscated}, "8 999 123 45 67"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signals := Analyze(tt.text, allowlist)
+			var types []SignalType
+			for _, s := range signals {
+				types = append(types, s.Type)
+			}
+			if !slices.Equal(types, tt.types) {
+				t.Fatalf("Analyze(%q) = %v, want %v", tt.text, signals, tt.types)
+			}
+			if len(signals) > 0 && signals[0].Match != tt.match {
+				t.Errorf("Analyze(%q)[0].Match = %q, want %q", tt.text, signals[0].Match, tt.match)
+			}
+		})
+	}
+}
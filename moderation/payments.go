@@ -0,0 +1,232 @@
+package moderation
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"math/big"
+	"regexp"
+	"strings"
+
+	"golang.org/x/crypto/sha3"
+)
+
+var (
+	// 13–19 цифр, можно группами через пробел или дефис
+	cardPattern = regexp.MustCompile(`\b\d(?:[ \-]?\d){12,18}\b`)
+
+	emailPattern = regexp.MustCompile(`(?i)\b[a-z0-9._%+\-]+@[a-z0-9.\-]+\.[a-z]{2,}\b`)
+
+	// Bitcoin (1…, 3…), Litecoin (L…, M…), Dogecoin (D…), TRON (T…) — base58check
+	base58Pattern = regexp.MustCompile(`\b[13LMDT][1-9A-HJ-NP-Za-km-z]{25,34}\b`)
+	// Bitcoin и Litecoin SegWit — bech32
+	bech32Pattern = regexp.MustCompile(`(?i)\b(?:bc|ltc)1[02-9ac-hj-np-z]{8,87}\b`)
+	// Ethereum и совместимые сети (USDT ERC-20, BEP-20)
+	ethPattern = regexp.MustCompile(`\b0x[0-9a-fA-F]{40}\b`)
+	// TON: 36 байт в base64url — 48 символов, начинаются с EQ/UQ/kQ/0Q
+	tonPattern = regexp.MustCompile(`\b[EUk0]Q[A-Za-z0-9_\-]{46}\b`)
+)
+
+// Версии адресов base58check: первый байт после декодирования
+var base58Versions = map[byte]bool{
+	0x00: true, // Bitcoin P2PKH
+	0x05: true, // Bitcoin P2SH
+	0x30: true, // Litecoin
+	0x32: true, // Litecoin P2SH
+	0x1e: true, // Dogecoin
+	0x41: true, // TRON
+}
+
+// findCards — номера карт, прошедшие проверку Луна. Берутся только
+// префиксы платёжных систем: Мир, Visa, Mastercard, Amex, UnionPay,
+// Uzcard и Humo — иначе за карту сойдёт любой длинный номер заказа.
+func findCards(text string) []string {
+	var cards []string
+	for _, match := range cardPattern.FindAllString(text, -1) {
+		digits := strings.NewReplacer(" ", "", "-", "").Replace(match)
+		// К номеру карты могли прилипнуть цифры после него
+		if !isCardNumber(digits) && !(len(digits) > 16 && isCardNumber(digits[:16])) {
+			continue
+		}
+		cards = append(cards, match)
+	}
+	return cards
+}
+
+func isCardNumber(digits string) bool {
+	if len(digits) < 13 || len(digits) > 19 {
+		return false
+	}
+	if !strings.ContainsAny(digits[:1], "23456") &&
+		!strings.HasPrefix(digits, "8600") && !strings.HasPrefix(digits, "9860") {
+		return false
+	}
+	return luhn(digits)
+}
+
+// luhn — контрольная сумма номера карты
+func luhn(digits string) bool {
+	sum := 0
+	double := false
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// findCryptoAddresses — адреса кошельков с верной контрольной суммой.
+// У адресов Ethereum в одном регистре контрольной суммы нет, они
+// принимаются по формату.
+func findCryptoAddresses(text string) []string {
+	var found []string
+	for _, match := range base58Pattern.FindAllString(text, -1) {
+		if isBase58Address(match) {
+			found = append(found, match)
+		}
+	}
+	for _, match := range bech32Pattern.FindAllString(text, -1) {
+		if isBech32Address(match) {
+			found = append(found, match)
+		}
+	}
+	for _, match := range ethPattern.FindAllString(text, -1) {
+		if isEthAddress(match) {
+			found = append(found, match)
+		}
+	}
+	for _, match := range tonPattern.FindAllString(text, -1) {
+		if isTonAddress(match) {
+			found = append(found, match)
+		}
+	}
+	return found
+}
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// isBase58Address: версия, 20 байт хэша и 4 байта двойного SHA-256
+func isBase58Address(addr string) bool {
+	n := new(big.Int)
+	for _, r := range addr {
+		i := strings.IndexRune(base58Alphabet, r)
+		if i < 0 {
+			return false
+		}
+		n.Mul(n, big.NewInt(58))
+		n.Add(n, big.NewInt(int64(i)))
+	}
+	// Ведущие единицы — нулевые байты
+	zeros := len(addr) - len(strings.TrimLeft(addr, "1"))
+	decoded := append(make([]byte, zeros), n.Bytes()...)
+	if len(decoded) != 25 || !base58Versions[decoded[0]] {
+		return false
+	}
+	first := sha256.Sum256(decoded[:21])
+	second := sha256.Sum256(first[:])
+	return bytes.Equal(second[:4], decoded[21:])
+}
+
+const bech32Charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+// isBech32Address проверяет контрольную сумму bech32 (SegWit v0)
+// и bech32m (Taproot)
+func isBech32Address(addr string) bool {
+	if addr != strings.ToLower(addr) && addr != strings.ToUpper(addr) {
+		return false
+	}
+	addr = strings.ToLower(addr)
+	sep := strings.LastIndexByte(addr, '1')
+	hrp, data := addr[:sep], addr[sep+1:]
+	if len(data) < 6 {
+		return false
+	}
+
+	values := make([]int, 0, len(hrp)*2+1+len(data))
+	for _, c := range hrp {
+		values = append(values, int(c)>>5)
+	}
+	values = append(values, 0)
+	for _, c := range hrp {
+		values = append(values, int(c)&31)
+	}
+	for _, c := range data {
+		values = append(values, strings.IndexRune(bech32Charset, c))
+	}
+
+	switch bech32Polymod(values) {
+	case 1, 0x2bc830a3: // bech32, bech32m
+		return true
+	}
+	return false
+}
+
+func bech32Polymod(values []int) int {
+	gen := []int{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+	chk := 1
+	for _, v := range values {
+		top := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ v
+		for i := 0; i < 5; i++ {
+			if (top>>i)&1 == 1 {
+				chk ^= gen[i]
+			}
+		}
+	}
+	return chk
+}
+
+// isEthAddress проверяет контрольную сумму EIP-55 в регистре букв
+func isEthAddress(addr string) bool {
+	body := addr[2:]
+	if body == strings.ToLower(body) || body == strings.ToUpper(body) {
+		return true
+	}
+	// Keccak-256 в варианте Ethereum, а не SHA3-256
+	keccak := sha3.NewLegacyKeccak256()
+	keccak.Write([]byte(strings.ToLower(body)))
+	hash := hex.EncodeToString(keccak.Sum(nil))
+	for i, c := range body {
+		if c >= '0' && c <= '9' {
+			continue
+		}
+		upper := hash[i] >= '8'
+		if upper != (c >= 'A' && c <= 'F') {
+			return false
+		}
+	}
+	return true
+}
+
+// isTonAddress: флаги, workchain, 32 байта хэша и CRC16-XMODEM
+func isTonAddress(addr string) bool {
+	decoded, err := base64.RawURLEncoding.DecodeString(addr)
+	if err != nil || len(decoded) != 36 {
+		return false
+	}
+	crc := crc16(decoded[:34])
+	return decoded[34] == byte(crc>>8) && decoded[35] == byte(crc)
+}
+
+func crc16(data []byte) uint16 {
+	var crc uint16
+	for _, b := range data {
+		crc ^= uint16(b) << 8
+		for i := 0; i < 8; i++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
@@ -0,0 +1,112 @@
+package moderation
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestFindCryptoAddresses(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		// Base58check
+		{"bitcoin P2PKH", "btc: 1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNa", []string{"1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNa"}},
+		{"bitcoin P2SH", "3J98t1WpEZ73CNmQviecrnyiWrnqRhWNLy", []string{"3J98t1WpEZ73CNmQviecrnyiWrnqRhWNLy"}},
+		{"bitcoin с ошибкой", "1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNb", nil},
+		{"litecoin", "LVg2kJoFNg45Nbpy53h7Fe1wKyeXVRhMH9", []string{"LVg2kJoFNg45Nbpy53h7Fe1wKyeXVRhMH9"}},
+		{"litecoin с ошибкой", "LVg2kJoFNg45Nbpy53h7Fe1wKyeXVRhMH8", nil},
+		{"dogecoin", "DH5yaieqoZN36fDVciNyRueRGvGLR3mr7L", []string{"DH5yaieqoZN36fDVciNyRueRGvGLR3mr7L"}},
+		{"dogecoin с ошибкой", "DH5yaieqoZN36fDVciNyRueRGvGLR3mr7l", nil},
+		{"tron", "USDT TRC-20: TR7NHqjeKQxGTCi8q8ZY4pL8otSzgjLj6t", []string{"TR7NHqjeKQxGTCi8q8ZY4pL8otSzgjLj6t"}},
+		{"tron с ошибкой", "TR7NHqjeKQxGTCi8q8ZY4pL8otSzgjLj6T", nil},
+
+		// Bech32 и bech32m
+		{"segwit", "bc1qar0srrr7xfkvy5l643lydnw9re59gtzzwf5mdq", []string{"bc1qar0srrr7xfkvy5l643lydnw9re59gtzzwf5mdq"}},
+		{"segwit в верхнем регистре", "BC1QAR0SRRR7XFKVY5L643LYDNW9RE59GTZZWF5MDQ", []string{"BC1QAR0SRRR7XFKVY5L643LYDNW9RE59GTZZWF5MDQ"}},
+		{"taproot", "bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqzk5jj0",
+			[]string{"bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqzk5jj0"}},
+		{"litecoin segwit", "ltc1qqpzry9x8gf2tvdw0s3jn54khce6mua7l4r80sk", []string{"ltc1qqpzry9x8gf2tvdw0s3jn54khce6mua7l4r80sk"}},
+		{"segwit с ошибкой", "bc1qar0srrr7xfkvy5l643lydnw9re59gtzzwf5mdp", nil},
+		{"segwit в смешанном регистре", "bc1qAr0srrr7xfkvy5l643lydnw9re59gtzzwf5mdq", nil},
+
+		// Ethereum: векторы EIP-55
+		{"eip-55", "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed", []string{"0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed"}},
+		{"eip-55 2", "0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359", []string{"0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359"}},
+		{"eip-55 3", "0xdbF03B407c01E7cD3CBea99509d93f8DDDC8C6FB", []string{"0xdbF03B407c01E7cD3CBea99509d93f8DDDC8C6FB"}},
+		{"eip-55 4", "0xD1220A0cf47c7B9Be7A2E6BA89F429762e7b9aDb", []string{"0xD1220A0cf47c7B9Be7A2E6BA89F429762e7b9aDb"}},
+		{"eip-55 с ошибкой в регистре", "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAeD", nil},
+		{"eth в нижнем регистре", "0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed", []string{"0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed"}},
+		{"eth короче 40 символов", "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeA", nil},
+
+		// TON
+		{"ton", "EQCD39VS5jcptHL8vMjEXrzGaRcCVYto7HUn4bpAOg8xqB2N", []string{"EQCD39VS5jcptHL8vMjEXrzGaRcCVYto7HUn4bpAOg8xqB2N"}},
+		{"ton с ошибкой", "EQCD39VS5jcptHL8vMjEXrzGaRcCVYto7HUn4bpAOg8xqB2M", nil},
+
+		{"обычный текст", "Продам велосипед, 1500 руб., звонить после 18:00", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := findCryptoAddresses(tt.text); !slices.Equal(got, tt.want) {
+				t.Errorf("findCryptoAddresses(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindCards(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{"visa", "карта 4111 1111 1111 1111", []string{"4111 1111 1111 1111"}},
+		{"mastercard", "5555555555554444", []string{"5555555555554444"}},
+		{"мир через дефис", "2200-0000-0000-0004", []string{"2200-0000-0000-0004"}},
+		{"unionpay", "6200000000000005", []string{"6200000000000005"}},
+		{"uzcard", "8600 1234 5678 9012", []string{"8600 1234 5678 9012"}},
+		{"humo", "9860123456789015", []string{"9860123456789015"}},
+		{"цифры после номера карты", "4111111111111111222", []string{"4111111111111111222"}},
+
+		{"ошибка в контрольной сумме", "4111 1111 1111 1112", nil},
+		{"чужой префикс", "1234567812345670", nil},
+		{"номер заказа из 18 цифр", "заказ №400000000000000123", nil},
+		{"номер заказа из 18 цифр 2", "заказ 412345678901234567", nil},
+		{"номер заказа с префиксом 1", "трек 10000000000000000", nil},
+		{"телефон", "8 999 123 45 67", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := findCards(tt.text); !slices.Equal(got, tt.want) {
+				t.Errorf("findCards(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLuhn(t *testing.T) {
+	tests := []struct {
+		digits string
+		want   bool
+	}{
+		{"4111111111111111", true},
+		{"5555555555554444", true},
+		{"2200000000000004", true},
+		{"79927398713", true},
+		{"4111111111111112", false},
+		{"79927398710", false},
+	}
+	for _, tt := range tests {
+		if got := luhn(tt.digits); got != tt.want {
+			t.Errorf("luhn(%q) = %v, want %v", tt.digits, got, tt.want)
+		}
+	}
+}
+
+func TestCRC16(t *testing.T) {
+	// Контрольное значение CRC-16/XMODEM
+	if got := crc16([]byte("123456789")); got != 0x31c3 {
+		t.Errorf("crc16 = %#04x, want 0x31c3", got)
+	}
+}
@@ -0,0 +1,115 @@
+package moderation
+
+import (
+	"image"
+	"math"
+	"sort"
+)
+
+// SimilarPhotoDistance — сколько бит перцептивных хэшей могут различаться
+// у одного и того же фото после пересжатия, масштабирования или обрезки краёв
+const SimilarPhotoDistance = 6
+
+// pHash считается по уменьшенному до 32×32 изображению, из DCT берутся 8×8 низких частот
+const (
+	phashSize    = 32
+	phashLowFreq = 8
+)
+
+// PHash — перцептивный хэш изображения: яркость уменьшается до 32×32,
+// над ней считается DCT, и каждый бит хэша — выше ли медианы коэффициент
+// из области низких частот. Пересжатие, масштаб и небольшие правки
+// почти не меняют хэш, поэтому повторы ищутся по расстоянию Хэмминга.
+func PHash(img image.Image) uint64 {
+	pixels := grayscale(img, phashSize)
+	freq := dct2D(pixels)
+
+	coeffs := make([]float64, 0, phashLowFreq*phashLowFreq)
+	for y := 0; y < phashLowFreq; y++ {
+		for x := 0; x < phashLowFreq; x++ {
+			coeffs = append(coeffs, freq[y][x])
+		}
+	}
+
+	// Постоянная составляющая (средняя яркость) в медиану не входит
+	sorted := append([]float64(nil), coeffs[1:]...)
+	sort.Float64s(sorted)
+	median := (sorted[len(sorted)/2-1] + sorted[len(sorted)/2]) / 2
+
+	var hash uint64
+	for i, c := range coeffs {
+		if c > median {
+			hash |= 1 << i
+		}
+	}
+	return hash
+}
+
+// grayscale уменьшает изображение до size×size, усредняя яркость
+// всех исходных пикселей, попавших в ячейку
+func grayscale(img image.Image, size int) [][]float64 {
+	b := img.Bounds()
+	sum := make([][]float64, size)
+	count := make([][]int, size)
+	for i := range sum {
+		sum[i] = make([]float64, size)
+		count[i] = make([]int, size)
+	}
+
+	w, h := b.Dx(), b.Dy()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		cy := (y - b.Min.Y) * size / h
+		for x := b.Min.X; x < b.Max.X; x++ {
+			cx := (x - b.Min.X) * size / w
+			r, g, bl, _ := img.At(x, y).RGBA()
+			sum[cy][cx] += 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(bl)
+			count[cy][cx]++
+		}
+	}
+
+	for y := range sum {
+		for x := range sum[y] {
+			if count[y][x] > 0 {
+				sum[y][x] /= float64(count[y][x])
+			}
+		}
+	}
+	return sum
+}
+
+// dct2D — двумерное DCT-II: по строкам, затем по столбцам
+func dct2D(pixels [][]float64) [][]float64 {
+	n := len(pixels)
+	rows := make([][]float64, n)
+	for y := range pixels {
+		rows[y] = dct1D(pixels[y])
+	}
+
+	out := make([][]float64, n)
+	for y := range out {
+		out[y] = make([]float64, n)
+	}
+	col := make([]float64, n)
+	for x := 0; x < n; x++ {
+		for y := 0; y < n; y++ {
+			col[y] = rows[y][x]
+		}
+		for y, v := range dct1D(col) {
+			out[y][x] = v
+		}
+	}
+	return out
+}
+
+func dct1D(in []float64) []float64 {
+	n := len(in)
+	out := make([]float64, n)
+	for k := 0; k < n; k++ {
+		var s float64
+		for i, v := range in {
+			s += v * math.Cos(math.Pi/float64(n)*(float64(i)+0.5)*float64(k))
+		}
+		out[k] = s
+	}
+	return out
+}
@@ -0,0 +1,42 @@
+package moderation
+
+import (
+	"errors"
+	"image"
+
+	"github.com/makiuchi-d/gozxing"
+	"github.com/makiuchi-d/gozxing/qrcode"
+)
+
+// DecodeQR ищет на изображении QR-код и возвращает его содержимое.
+// "" без ошибки — кода на изображении нет.
+func DecodeQR(img image.Image) (string, error) {
+	bmp, err := gozxing.NewBinaryBitmapFromImage(img)
+	if err != nil {
+		return "", err
+	}
+	hints := map[gozxing.DecodeHintType]interface{}{gozxing.DecodeHintType_TRY_HARDER: true}
+	res, err := qrcode.NewQRCodeReader().Decode(bmp, hints)
+	if err != nil {
+		// Кода нет или он не читается — обычная картинка
+		var readerErr gozxing.ReaderException
+		if errors.As(err, &readerErr) {
+			return "", nil
+		}
+		return "", err
+	}
+	return res.GetText(), nil
+}
+
+// AnalyzeQR проверяет содержимое QR-кода так же, как текст сообщения:
+// ссылки из белого списка пропускаются. Сигналы помечаются entity "qr".
+func AnalyzeQR(content string, allowlist Allowlist) []Signal {
+	if content == "" {
+		return nil
+	}
+	signals := Analyze(content, allowlist)
+	for i := range signals {
+		signals[i].Entity = "qr"
+	}
+	return signals
+}
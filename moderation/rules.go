@@ -0,0 +1,136 @@
+package moderation
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+)
+
+type RuleKind string
+
+const (
+	RuleRegex    RuleKind = "regex"
+	RuleKeywords RuleKind = "keywords"
+)
+
+// Rule — правило детектора из БД: регулярное выражение или список ключевых слов
+type Rule struct {
+	ID      int
+	GroupID int64 // 0 — для всех групп
+	Name    string
+	Kind    RuleKind
+	Pattern string
+	Weight  int
+	Action  Action // если задано — действие при срабатывании независимо от оценки
+	Shadow  bool   // теневое правило: срабатывания только записываются
+
+	re       *regexp.Regexp
+	keywords []string
+}
+
+// Compile готовит правило к проверке. Ключевые слова нормализуются так же,
+// как текст сообщения, поэтому «работа на дому» ловит и «рабоTа на дому».
+func (r *Rule) Compile() error {
+	switch r.Kind {
+	case RuleRegex:
+		re, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			return fmt.Errorf("правило %d: %w", r.ID, err)
+		}
+		r.re = re
+
+	case RuleKeywords:
+		r.keywords = nil
+		for _, kw := range strings.FieldsFunc(r.Pattern, func(c rune) bool { return c == ',' || c == '\n' }) {
+			if kw = strings.TrimSpace(Normalize(kw)); kw != "" {
+				r.keywords = append(r.keywords, kw)
+			}
+		}
+		if len(r.keywords) == 0 {
+			return fmt.Errorf("правило %d: пустой список слов", r.ID)
+		}
+
+	default:
+		return fmt.Errorf("правило %d: неизвестный тип %q", r.ID, r.Kind)
+	}
+
+	switch r.Action {
+	case ActionNone, ActionWarn, ActionDelete:
+	default:
+		return fmt.Errorf("правило %d: неизвестное действие %q", r.ID, r.Action)
+	}
+	return nil
+}
+
+// Match возвращает сработавший фрагмент текста или пустую строку
+func (r *Rule) Match(text string) string {
+	return r.match(text, Normalize(text))
+}
+
+func (r *Rule) match(text, normalized string) string {
+	if r.re != nil {
+		if m := r.re.FindString(text); m != "" {
+			return m
+		}
+		return r.re.FindString(normalized)
+	}
+
+	for _, kw := range r.keywords {
+		if strings.Contains(normalized, kw) {
+			return kw
+		}
+	}
+	return ""
+}
+
+// Signal — сигнал сработавшего правила
+func (r *Rule) Signal(match string) Signal {
+	return Signal{
+		Type:   SignalRule,
+		Match:  match,
+		Weight: r.Weight,
+		RuleID: r.ID,
+		Rule:   r.Name,
+		Action: r.Action,
+		Shadow: r.Shadow,
+	}
+}
+
+// RuleSet — скомпилированные включённые правила
+type RuleSet []*Rule
+
+// NewRuleSet компилирует правила. Некорректные пропускаются с предупреждением в лог.
+func NewRuleSet(rules []*Rule) RuleSet {
+	set := make(RuleSet, 0, len(rules))
+	for _, r := range rules {
+		if err := r.Compile(); err != nil {
+			log.Printf("Пропущено правило спама: %v", err)
+			continue
+		}
+		set = append(set, r)
+	}
+	return set
+}
+
+// Apply проверяет текст правилами группы chatID и общими правилами
+func (s RuleSet) Apply(chatID int64, text string) []Signal {
+	if text == "" {
+		return nil
+	}
+
+	var signals []Signal
+	normalized := ""
+	for _, r := range s {
+		if r.GroupID != 0 && r.GroupID != chatID {
+			continue
+		}
+		if normalized == "" {
+			normalized = Normalize(text)
+		}
+		if m := r.match(text, normalized); m != "" {
+			signals = append(signals, r.Signal(m))
+		}
+	}
+	return signals
+}
@@ -0,0 +1,248 @@
+package moderation
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type SignalType string
+
+const (
+	SignalPhone   SignalType = "phone"
+	SignalLink    SignalType = "link"
+	SignalContact SignalType = "contact"
+	// Упоминание пользователя: вес ниже порогов, само по себе не спам
+	SignalMention SignalType = "mention"
+	// Контакт найден только после нормализации текста
+	SignalObfuscated SignalType = "obfuscated"
+	// Пользователь незнаком боту (не покупал размещение)
+	SignalNewUser SignalType = "new_user"
+	// Тот же текст от того же пользователя недавно уже был в группе
+	SignalRepeatedText SignalType = "repeated_text"
+	// У пользователя уже были нарушения
+	SignalRepeatOffender SignalType = "repeat_offender"
+	// Сработало правило из БД (вес задаёт правило)
+	SignalRule SignalType = "rule"
+	// Вложения, которыми передают контакт в обход текста
+	SignalContactCard SignalType = "contact_card"
+	SignalLocation    SignalType = "location"
+	SignalStory       SignalType = "story"
+	// Пересылка из незнакомого канала
+	SignalForward SignalType = "forward"
+	// Номер карты (проверка Луна), адрес криптокошелька, email
+	SignalCard   SignalType = "card"
+	SignalCrypto SignalType = "crypto_wallet"
+	SignalEmail  SignalType = "email"
+	// Байесовский классификатор счёл текст спамом
+	SignalBayes SignalType = "bayes"
+)
+
+// Weights — вес каждого сигнала в итоговой оценке
+var Weights = map[SignalType]int{
+	SignalPhone:          100,
+	SignalLink:           60,
+	SignalContact:        60,
+	SignalMention:        10,
+	SignalObfuscated:     20,
+	SignalNewUser:        15,
+	SignalRepeatedText:   30,
+	SignalRepeatOffender: 20,
+	SignalContactCard:    100,
+	SignalLocation:       60,
+	SignalStory:          60,
+	SignalForward:        60,
+	SignalBayes:          50,
+	SignalCard:           100,
+	SignalCrypto:         100,
+	SignalEmail:          60,
+}
+
+// Signal — один признак спама с его весом
+type Signal struct {
+	Type  SignalType `json:"type"`
+	Match string     `json:"match,omitempty"`
+	// Entity — тип entity сообщения (text_link, mention...), если сигнал найден в ней
+	Entity string `json:"entity,omitempty"`
+	Weight int    `json:"weight"`
+	// Для сигналов правил из БД
+	RuleID int    `json:"rule_id,omitempty"`
+	Rule   string `json:"rule,omitempty"`
+	Action Action `json:"action,omitempty"`
+	// Shadow — сигнал теневого правила: записывается, но не влияет на действие
+	Shadow bool `json:"shadow,omitempty"`
+}
+
+func NewSignal(t SignalType, match string) Signal {
+	return Signal{Type: t, Match: match, Weight: Weights[t]}
+}
+
+// IsContent — сигнал найден в самом сообщении, а не в контексте (автор, история)
+func (s Signal) IsContent() bool {
+	switch s.Type {
+	case SignalPhone, SignalLink, SignalContact, SignalObfuscated, SignalRule,
+		SignalContactCard, SignalLocation, SignalStory, SignalForward, SignalBayes,
+		SignalCard, SignalCrypto, SignalEmail:
+		return true
+	}
+	return false
+}
+
+// HasContent — есть ли среди сигналов найденные в самом сообщении
+func HasContent(signals []Signal) bool {
+	for _, s := range signals {
+		if s.IsContent() {
+			return true
+		}
+	}
+	return false
+}
+
+func (s Signal) String() string {
+	str := fmt.Sprintf("%s +%d", s.Type, s.Weight)
+	if s.Rule != "" {
+		str = fmt.Sprintf("%s #%d «%s» +%d", s.Type, s.RuleID, s.Rule, s.Weight)
+	}
+	if s.Action != ActionNone {
+		str += " [" + string(s.Action) + "]"
+	}
+	if s.Shadow {
+		str += " [shadow]"
+	}
+	if s.Match != "" {
+		str += ": " + s.Match
+	}
+	if s.Entity != "" {
+		str += " (" + s.Entity + ")"
+	}
+	return str
+}
+
+// signalSet собирает сигналы без повторов: совпадение, уже входящее
+// в найденное ранее, не добавляется
+type signalSet []Signal
+
+func (set *signalSet) add(s Signal) {
+	for _, prev := range *set {
+		if prev.Match != "" && strings.Contains(prev.Match, s.Match) {
+			return
+		}
+	}
+	*set = append(*set, s)
+}
+
+type Action string
+
+const (
+	ActionNone   Action = ""
+	ActionWarn   Action = "warn"
+	ActionDelete Action = "delete"
+)
+
+// Thresholds — пороги оценки для предупреждения и удаления
+type Thresholds struct {
+	Warn   int
+	Delete int
+}
+
+// Verdict — итог проверки сообщения
+type Verdict struct {
+	Signals []Signal
+	Score   int
+	Action  Action
+	// Что было бы сделано с учётом теневых правил или теневого режима группы.
+	// Заполняется, только если строже Action.
+	ShadowScore  int
+	ShadowAction Action
+}
+
+// Evaluate суммирует веса сигналов и сравнивает с порогами.
+// Действие сработавшего правила повышает итоговое действие.
+// Сигналы сортируются по убыванию веса — первый и есть главная причина.
+func Evaluate(signals []Signal, th Thresholds) Verdict {
+	sorted := append([]Signal(nil), signals...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Weight > sorted[j].Weight })
+
+	v := Verdict{Signals: sorted}
+	v.Score, v.Action = score(sorted, th, false)
+
+	if shadowScore, shadowAction := score(sorted, th, true); shadowAction.severity() > v.Action.severity() {
+		v.ShadowScore, v.ShadowAction = shadowScore, shadowAction
+	}
+	return v
+}
+
+// score считает оценку и действие; withShadow — с учётом теневых сигналов
+func score(signals []Signal, th Thresholds, withShadow bool) (int, Action) {
+	total := 0
+	for _, s := range signals {
+		if !s.Shadow || withShadow {
+			total += s.Weight
+		}
+	}
+
+	action := ActionNone
+	switch {
+	case total >= th.Delete:
+		action = ActionDelete
+	case total >= th.Warn:
+		action = ActionWarn
+	}
+
+	// Правило с действием срабатывает независимо от оценки
+	for _, s := range signals {
+		if (!s.Shadow || withShadow) && s.Action.severity() > action.severity() {
+			action = s.Action
+		}
+	}
+	return total, action
+}
+
+// Shadow переводит вердикт в теневой режим: действие только записывается
+func (v *Verdict) Shadow() {
+	if v.Action.severity() > v.ShadowAction.severity() {
+		v.ShadowScore, v.ShadowAction = v.Score, v.Action
+	}
+	v.Action = ActionNone
+}
+
+// Cap ограничивает действие вердикта: например, для смягчённой проверки
+// удаление заменяется предупреждением. Возвращает true, если действие изменилось.
+func (v *Verdict) Cap(max Action) bool {
+	if v.Action.severity() <= max.severity() {
+		return false
+	}
+	v.Action = max
+	return true
+}
+
+func (a Action) severity() int {
+	switch a {
+	case ActionDelete:
+		return 2
+	case ActionWarn:
+		return 1
+	}
+	return 0
+}
+
+// Top — сигнал с наибольшим весом
+func (v Verdict) Top() Signal {
+	if len(v.Signals) == 0 {
+		return Signal{}
+	}
+	return v.Signals[0]
+}
+
+// Explain — объяснение оценки для модераторов: «phone +100: 8999… ; new_user +15 = 115»
+func (v Verdict) Explain() string {
+	parts := make([]string, len(v.Signals))
+	for i, s := range v.Signals {
+		parts[i] = s.String()
+	}
+	explain := fmt.Sprintf("%s = %d", strings.Join(parts, "; "), v.Score)
+	if v.ShadowAction != ActionNone && v.ShadowScore != v.Score {
+		explain += fmt.Sprintf(" (с теневыми правилами %d)", v.ShadowScore)
+	}
+	return explain
+}
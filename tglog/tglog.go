@@ -30,6 +30,15 @@ func Init(tgBot *bot.Bot, chID int64) {
 
 // Send отправляет сообщение в лог-канал (неблокирующий)
 func Send(format string, args ...any) {
+	send(nil, format, args...)
+}
+
+// SendWithMarkup отправляет сообщение с кнопками — например, для решения модераторов
+func SendWithMarkup(markup models.ReplyMarkup, format string, args ...any) {
+	send(markup, format, args...)
+}
+
+func send(markup models.ReplyMarkup, format string, args ...any) {
 	if !enabled {
 		return
 	}
@@ -38,9 +47,10 @@ func Send(format string, args ...any) {
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 		_, err := b.SendMessage(ctx, &bot.SendMessageParams{
-			ChatID:    channelID,
-			Text:      text,
-			ParseMode: models.ParseModeHTML,
+			ChatID:      channelID,
+			Text:        text,
+			ParseMode:   models.ParseModeHTML,
+			ReplyMarkup: markup,
 		})
 		if err != nil {
 			log.Printf("Ошибка отправки лога в канал: %v", err)